@@ -0,0 +1,315 @@
+// Package cassette provides an http.RoundTripper recording HTTP interactions into a file, and replaying them
+// afterwards, so that tests calling external APIs can run offline.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/morelj/httptools/header"
+)
+
+// Redacted is the value replacing redacted headers in cassettes
+const Redacted = "<redacted>"
+
+// Mode is the mode of a Recorder.
+type Mode int
+
+const (
+	// Auto replays the cassette if its file exists, and records it otherwise.
+	Auto Mode = iota
+	// Record always sends requests, and records them. An existing cassette is overwritten.
+	Record
+	// Replay never sends requests, they are replayed from the cassette.
+	Replay
+)
+
+// Body is the body of a recorded request or response.
+// It is stored as a string when it's valid UTF-8, and encoded using base64 otherwise.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	*b = decoded
+	return err
+}
+
+// Request is a recorded request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Interaction is a recorded request along with its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the content of a cassette file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Config is the configuration of a Recorder.
+type Config struct {
+	// Path is the path of the cassette file.
+	Path string
+
+	// Mode is the mode of the Recorder.
+	Mode Mode
+
+	// Matcher decides whether a request matches a recorded request. If nil, DefaultMatcher is used.
+	Matcher Matcher
+
+	// RedactHeaders lists the request and response headers whose value is replaced by Redacted in the cassette.
+	// If nil, DefaultRedactHeaders is used.
+	RedactHeaders []string
+}
+
+// DefaultRedactHeaders are the headers redacted by default.
+var DefaultRedactHeaders = []string{
+	header.Authorization,
+	header.ProxyAuthorization,
+	header.Cookie,
+	header.SetCookie,
+	"X-Api-Key",
+}
+
+// Recorder is an http.RoundTripper recording or replaying a cassette.
+type Recorder struct {
+	next http.RoundTripper
+	cfg  Config
+	mode Mode
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New returns a Recorder using next to send requests when recording.
+// If next is nil, http.DefaultTransport is used.
+// When replaying, the cassette is loaded immediately.
+//
+// When recording, Stop must be called to write the cassette.
+func New(next http.RoundTripper, cfg Config) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if cfg.Matcher == nil {
+		cfg.Matcher = DefaultMatcher
+	}
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = DefaultRedactHeaders
+	}
+
+	rec := &Recorder{
+		next: next,
+		cfg:  cfg,
+		mode: cfg.Mode,
+	}
+	if rec.mode == Auto {
+		if _, err := os.Stat(cfg.Path); err == nil {
+			rec.mode = Replay
+		} else if errors.Is(err, os.ErrNotExist) {
+			rec.mode = Record
+		} else {
+			return nil, err
+		}
+	}
+
+	if rec.mode == Replay {
+		data, err := os.ReadFile(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &rec.cassette); err != nil {
+			return nil, fmt.Errorf("Invalid cassette %s: %w", cfg.Path, err)
+		}
+		rec.used = make([]bool, len(rec.cassette.Interactions))
+	}
+	return rec, nil
+}
+
+// Mode returns the effective mode of the Recorder, which is either Record or Replay
+func (rec *Recorder) Mode() Mode {
+	return rec.mode
+}
+
+// RoundTrip implements http.RoundTripper.
+// As required by http.RoundTripper, r is not modified: its body is read and closed, and a clone of r is sent to
+// the next RoundTripper when recording.
+func (rec *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rec.mode == Replay {
+		return rec.replay(r, body)
+	}
+	return rec.record(r, body)
+}
+
+// replay returns the response of the first unused interaction matching r
+func (rec *Recorder) replay(r *http.Request, body []byte) (*http.Response, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	for i, interaction := range rec.cassette.Interactions {
+		if rec.used[i] || !rec.cfg.Matcher(r, body, interaction.Request) {
+			continue
+		}
+		rec.used[i] = true
+
+		resp := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+			StatusCode:    resp.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        resp.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+			ContentLength: int64(len(resp.Body)),
+			Request:       r,
+		}, nil
+	}
+	return nil, fmt.Errorf("cassette %s: no recorded interaction matches %s %s", rec.cfg.Path, r.Method, r.URL)
+}
+
+// record sends a clone of r, having body as its body, and records the interaction
+func (rec *Recorder) record(r *http.Request, body []byte) (*http.Response, error) {
+	out := r.Clone(r.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	resp, err := rec.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method: r.Method,
+			URL:    r.URL.String(),
+			Header: rec.redact(r.Header),
+			Body:   body,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     rec.redact(resp.Header),
+			Body:       respBody,
+		},
+	}
+
+	rec.mu.Lock()
+	rec.cassette.Interactions = append(rec.cassette.Interactions, interaction)
+	rec.mu.Unlock()
+
+	return resp, nil
+}
+
+// redact returns a copy of h with redacted headers
+func (rec *Recorder) redact(h http.Header) http.Header {
+	h = h.Clone()
+	for _, key := range rec.cfg.RedactHeaders {
+		if values := h.Values(key); len(values) > 0 {
+			h.Set(key, Redacted)
+		}
+	}
+	return h
+}
+
+// Stop writes the cassette if recording. When replaying, it returns an error if some interactions have not been
+// replayed.
+func (rec *Recorder) Stop() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.mode == Replay {
+		unused := 0
+		for _, used := range rec.used {
+			if !used {
+				unused++
+			}
+		}
+		if unused > 0 {
+			return fmt.Errorf("cassette %s: %d recorded interactions have not been replayed", rec.cfg.Path, unused)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(rec.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rec.cfg.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(rec.cfg.Path, append(data, '\n'), 0o644)
+}
+
+// readBody reads the body pointed to by body, and replaces it with a new reader on the read data
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
@@ -0,0 +1,134 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set(header.SetCookie, "session=secret")
+		io.WriteString(w, r.Method+" "+r.URL.Path+" "+string(data))
+	}))
+	path := filepath.Join(t.TempDir(), "cassettes", "test.json")
+	cfg := Config{
+		Path:    path,
+		Matcher: Match(MatchMethod, MatchURL, MatchBody, MatchHeaders(header.Authorization)),
+	}
+
+	send := func(client *http.Client, body string) string {
+		r, _ := http.NewRequest(http.MethodPost, server.URL+"/path", strings.NewReader(body))
+		r.Header.Set(header.Authorization, "Bearer token")
+		resp, err := client.Do(r)
+		require.NoError(err)
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	// Record
+	rec, err := New(nil, cfg)
+	require.NoError(err)
+	assert.Equal(Record, rec.Mode())
+	client := &http.Client{Transport: rec}
+	assert.Equal("POST /path a", send(client, "a"))
+	assert.Equal("POST /path b", send(client, "b"))
+	require.NoError(rec.Stop())
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+	assert.NotContains(string(data), "secret")
+	assert.NotContains(string(data), "Bearer token")
+
+	// Replay, the server is closed
+	rec, err = New(nil, cfg)
+	require.NoError(err)
+	assert.Equal(Replay, rec.Mode())
+	client = &http.Client{Transport: rec}
+	assert.Equal("POST /path b", send(client, "b"))
+	assert.Error(rec.Stop())
+	assert.Equal("POST /path a", send(client, "a"))
+	assert.NoError(rec.Stop())
+
+	// Unmatched requests fail
+	r, _ := http.NewRequest(http.MethodPost, server.URL+"/path", strings.NewReader("a"))
+	_, err = client.Do(r)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "no recorded interaction matches POST")
+	}
+}
+
+func TestBody(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, b := range []Body{Body("text"), Body{0xff, 0x00, 0x01}} {
+		data, err := b.MarshalJSON()
+		assert.NoError(err)
+		var decoded Body
+		assert.NoError(decoded.UnmarshalJSON(data))
+		assert.Equal(b, decoded)
+	}
+}
+
+// trackedBody records whether it has been closed
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestRoundTripDoesNotModifyRequest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var sent *http.Request
+	var sentBody string
+	next := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		sent = r
+		data, _ := io.ReadAll(r.Body)
+		sentBody = string(data)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+	rec, err := New(next, Config{Path: filepath.Join(t.TempDir(), "test.json")})
+	require.NoError(err)
+
+	body := &trackedBody{Reader: strings.NewReader("a")}
+	r, _ := http.NewRequest(http.MethodPost, "http://example.com/path", body)
+	resp, err := rec.RoundTrip(r)
+	require.NoError(err)
+	resp.Body.Close()
+
+	assert.True(body.closed)
+	assert.Same(body, r.Body)
+	assert.NotSame(r, sent)
+	assert.Equal("a", sentBody)
+	require.NoError(rec.Stop())
+}
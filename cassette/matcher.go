@@ -0,0 +1,62 @@
+package cassette
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// A Matcher decides whether a request, along with its body, matches a recorded request.
+type Matcher func(r *http.Request, body []byte, recorded Request) bool
+
+// DefaultMatcher matches requests by method and URL.
+var DefaultMatcher = Match(MatchMethod, MatchURL)
+
+// Match returns a Matcher matching requests matched by all the given matchers.
+func Match(matchers ...Matcher) Matcher {
+	return func(r *http.Request, body []byte, recorded Request) bool {
+		for _, m := range matchers {
+			if !m(r, body, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchMethod matches requests with the same method.
+func MatchMethod(r *http.Request, body []byte, recorded Request) bool {
+	return r.Method == recorded.Method
+}
+
+// MatchURL matches requests with the same URL.
+func MatchURL(r *http.Request, body []byte, recorded Request) bool {
+	return r.URL.String() == recorded.URL
+}
+
+// MatchBody matches requests with the same body.
+func MatchBody(r *http.Request, body []byte, recorded Request) bool {
+	return bytes.Equal(body, recorded.Body)
+}
+
+// MatchHeaders returns a Matcher matching requests with the same values for the given headers.
+// Redacted headers always match.
+func MatchHeaders(keys ...string) Matcher {
+	return func(r *http.Request, body []byte, recorded Request) bool {
+		for _, key := range keys {
+			expected := recorded.Header.Values(key)
+			if len(expected) == 1 && expected[0] == Redacted {
+				continue
+			}
+			actual := r.Header.Values(key)
+			if len(actual) != len(expected) {
+				return false
+			}
+			for i := range actual {
+				if actual[i] != expected[i] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,121 @@
+// Command crashlog reads the crash log of a Go process from stdin and prints a report.
+//
+// Usage:
+//
+//	go run ./cmd/crashlog [-json] < crash.log
+//
+// By default, a summary is printed: the panics or fatal error which caused the crash, the signal if any, the stack of
+// the goroutine which crashed without the runtime frames, and the number of goroutines by state.
+// With -json, the full parsed report is printed as JSON.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/morelj/httptools/stack"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "Print the report as JSON")
+	flag.Parse()
+
+	log, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read stdin: %v\n", err)
+		os.Exit(1)
+	}
+
+	crash, err := stack.ParseCrash(log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot parse crash log: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(crash)
+	} else {
+		err = printSummary(os.Stdout, crash)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot write report: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printSummary writes a human readable summary of the crash to w
+func printSummary(w io.Writer, crash stack.Crash) error {
+	var b strings.Builder
+
+	for i, p := range crash.Panics {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		fmt.Fprintf(&b, "panic: %s", strings.ReplaceAll(p.Value, "\n", "\n  "))
+		if p.Repanicked {
+			b.WriteString(" [recovered, repanicked]")
+		} else if p.Recovered {
+			b.WriteString(" [recovered]")
+		}
+		b.WriteByte('\n')
+	}
+	if crash.FatalError != "" {
+		fmt.Fprintf(&b, "fatal error: %s\n", crash.FatalError)
+	}
+	if len(crash.Panics) == 0 && crash.FatalError == "" {
+		b.WriteString("no panic nor fatal error found\n")
+	}
+	if s := crash.Signal; s != nil {
+		fmt.Fprintf(&b, "signal: %s", s.Name)
+		if s.Description != "" {
+			fmt.Fprintf(&b, " (%s)", s.Description)
+		}
+		fmt.Fprintf(&b, " addr=%#x pc=%#x\n", s.Addr, s.PC)
+	}
+
+	if crash.Goroutine() != nil {
+		filtered := crash.Stack.Filter(stack.DropRuntime).ShortenPaths()
+		g := filtered.Goroutines[0]
+
+		fmt.Fprintf(&b, "\n%s [%s]:\n", g.Name, g.State)
+		for _, e := range g.Elements {
+			fmt.Fprintf(&b, "  %s\n      %s:%d\n", e.FuncName(), e.File, e.Line)
+		}
+		if g.Elided {
+			b.WriteString("  ...\n")
+		}
+		if g.CreatedBy != nil {
+			fmt.Fprintf(&b, "  created by %s\n      %s:%d\n", g.CreatedBy.FuncName(), g.CreatedBy.File, g.CreatedBy.Line)
+		}
+
+		fmt.Fprintf(&b, "\n%d goroutine(s)", len(crash.Stack.Goroutines))
+		states := map[string]int{}
+		for _, g := range crash.Stack.Goroutines {
+			states[g.State]++
+		}
+		names := make([]string, 0, len(states))
+		for state := range states {
+			names = append(names, state)
+		}
+		sort.Strings(names)
+		for i, state := range names {
+			if i == 0 {
+				b.WriteString(": ")
+			} else {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%d %s", states[state], state)
+		}
+		b.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
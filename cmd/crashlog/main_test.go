@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/stack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintSummary(t *testing.T) {
+	cases := []struct {
+		log      string
+		expected string
+	}{
+		{
+			log: `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4830e8]
+
+goroutine 1 [running]:
+example.com/app/store.(*DB).Get(0x0, {0x4b2a10, 0x3})
+	/src/app/store/db.go:10 +0x8
+main.main()
+	/src/app/main.go:45 +0x88
+`,
+			expected: `panic: runtime error: invalid memory address or nil pointer dereference
+signal: SIGSEGV (segmentation violation) addr=0x0 pc=0x4830e8
+
+goroutine 1 [running]:
+  example.com/app/store.(*DB).Get
+      example.com/app/store/db.go:10
+  main.main
+      /src/app/main.go:45
+
+1 goroutine(s): 1 running
+`,
+		},
+		{
+			log: `fatal error: all goroutines are asleep - deadlock!
+
+goroutine 1 [chan receive]:
+main.deadlock()
+	/src/crash/main.go:38 +0x39
+main.main()
+	/src/crash/main.go:53 +0xea
+
+goroutine 6 [select (no cases)]:
+main.deadlock.func1()
+	/src/crash/main.go:37 +0xf
+created by main.deadlock in goroutine 1
+	/src/crash/main.go:37 +0x2d
+`,
+			expected: `fatal error: all goroutines are asleep - deadlock!
+
+goroutine 1 [chan receive]:
+  main.deadlock
+      /src/crash/main.go:38
+  main.main
+      /src/crash/main.go:53
+
+2 goroutine(s): 1 chan receive, 1 select (no cases)
+`,
+		},
+		{
+			log:      "nothing to see here\n",
+			expected: "no panic nor fatal error found\n",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			crash, err := stack.ParseCrash([]byte(c.log))
+			require.NoError(err)
+
+			var b strings.Builder
+			require.NoError(printSummary(&b, crash))
+			assert.Equal(c.expected, b.String())
+		})
+	}
+}
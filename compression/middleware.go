@@ -0,0 +1,288 @@
+package compression
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/morelj/httptools/header"
+)
+
+// Config is the configuration of the compression middleware.
+type Config struct {
+	// MinSize is the minimum size, in bytes, of a response body to be compressed.
+	// Smaller bodies are written uncompressed.
+	MinSize int
+
+	// Level is the compression level, as defined by compress/flate.
+	// Zero means flate.DefaultCompression, as a response which is not compressed must not be labelled as such.
+	Level int
+
+	// ExcludedContentTypes lists the media types which are never compressed, usually because they are already
+	// compressed. A trailing "/*" matches a whole top-level type (e.g. "image/*").
+	ExcludedContentTypes []string
+}
+
+// DefaultConfig is the configuration used by NewMiddleware.
+var DefaultConfig = Config{
+	MinSize: 1024,
+	Level:   flate.DefaultCompression,
+	ExcludedContentTypes: []string{
+		"image/*",
+		"audio/*",
+		"video/*",
+		"font/woff",
+		"font/woff2",
+		"application/gzip",
+		"application/x-gzip",
+		"application/zip",
+		"application/zstd",
+		"application/x-bzip2",
+		"application/x-xz",
+		"application/x-7z-compressed",
+		"application/vnd.rar",
+	},
+}
+
+// NewMiddleware returns a middleware which compresses responses using gzip or deflate, depending on the request's
+// Accept-Encoding header.
+// Calling NewMiddleware() is equivalent to calling NewCustomMiddleware(DefaultConfig)
+func NewMiddleware() mux.MiddlewareFunc {
+	return NewCustomMiddleware(DefaultConfig)
+}
+
+// NewCustomMiddleware returns a middleware which compresses responses using gzip or deflate, depending on the
+// request's Accept-Encoding header.
+//
+// The response is not compressed when:
+// - the body is smaller than cfg.MinSize, unless the response is flushed before
+// - the Content-Type of the response is excluded by cfg.ExcludedContentTypes
+// - the response already has a Content-Encoding, or is a partial (206) response
+// - the status code of the response does not allow a body
+//
+// Vary: Accept-Encoding is always added to the response, and Content-Length is removed from compressed responses.
+//
+// It panics if cfg.Level is not a valid compression level.
+func NewCustomMiddleware(cfg Config) mux.MiddlewareFunc {
+	if cfg.Level == 0 {
+		cfg.Level = flate.DefaultCompression
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, cfg.Level); err != nil {
+		panic(err)
+	}
+
+	pools := map[string]*sync.Pool{
+		Gzip: {New: func() any {
+			w, _ := gzip.NewWriterLevel(nil, cfg.Level)
+			return w
+		}},
+		Deflate: {New: func() any {
+			// The deflate coding is zlib-wrapped (RFC 9110 §8.4.1.2)
+			w, _ := zlib.NewWriterLevel(nil, cfg.Level)
+			return w
+		}},
+	}
+
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add(header.Vary, header.AcceptEncoding)
+
+			encoding := Negotiate(r.Header.Get(header.AcceptEncoding))
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &responseWriter{
+				ResponseWriter: w,
+				cfg:            &cfg,
+				encoding:       encoding,
+				pool:           pools[encoding],
+			}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	})
+}
+
+// encoder is the common interface of gzip.Writer and zlib.Writer
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// responseWriter buffers the beginning of the response until it can decide whether to compress it
+type responseWriter struct {
+	http.ResponseWriter
+	cfg      *Config
+	encoding string
+	pool     *sync.Pool
+
+	statusCode int
+	buf        []byte
+	decided    bool
+	enc        encoder
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if w.statusCode != 0 {
+		// Superfluous call, ignored as net/http would do
+		return
+	}
+	if statusCode < http.StatusOK {
+		// Informational responses are forwarded as is
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.statusCode = statusCode
+	if !bodyAllowed(statusCode) {
+		w.decide(false)
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 && !w.decided {
+		w.statusCode = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.cfg.MinSize {
+			return len(p), nil
+		}
+		// The body is large enough, the buffer can be written
+		n := len(p)
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+// A response flushed before reaching the minimum size is compressed anyway, as it is most likely a stream.
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		if w.statusCode == 0 {
+			w.statusCode = http.StatusOK
+		}
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return
+		}
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide writes the header of the response, along with the buffered body.
+// If compress is true, the response is compressed unless it's not eligible.
+func (w *responseWriter) decide(compress bool) error {
+	w.decided = true
+	h := w.Header()
+
+	if compress && w.shouldCompress(h) {
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+		h.Del(header.ContentLength)
+		h.Set(header.ContentEncoding, w.encoding)
+	}
+
+	if w.statusCode != 0 {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// shouldCompress returns true if the response is eligible for compression
+func (w *responseWriter) shouldCompress(h http.Header) bool {
+	if !bodyAllowed(w.statusCode) || w.statusCode == http.StatusPartialContent {
+		return false
+	}
+	if h.Get(header.ContentEncoding) != "" || h.Get(header.ContentRange) != "" {
+		return false
+	}
+
+	contentType := h.Get(header.ContentType)
+	if contentType == "" && len(w.buf) > 0 {
+		// Sniff the content type now, as it can't be done on compressed data
+		contentType = http.DetectContentType(w.buf)
+		h.Set(header.ContentType, contentType)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	for _, excluded := range w.cfg.ExcludedContentTypes {
+		if prefix, ok := strings.CutSuffix(excluded, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return false
+			}
+		} else if mediaType == excluded {
+			return false
+		}
+	}
+	return true
+}
+
+// close must be called once the handler returns
+func (w *responseWriter) close() {
+	if !w.decided {
+		if w.statusCode == 0 && len(w.buf) == 0 {
+			// Nothing has been written, let net/http write its default response
+			return
+		}
+		if w.statusCode == 0 {
+			w.statusCode = http.StatusOK
+		}
+		if err := w.decide(len(w.buf) >= w.cfg.MinSize); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// bodyAllowed returns true if a response with the given status code may have a body
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= http.StatusOK && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
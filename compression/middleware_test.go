@@ -0,0 +1,151 @@
+package compression
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{acceptEncoding: "", expected: ""},
+		{acceptEncoding: "gzip", expected: Gzip},
+		{acceptEncoding: "deflate, gzip", expected: Gzip},
+		{acceptEncoding: "deflate, gzip;q=0.5", expected: Deflate},
+		{acceptEncoding: "br, *;q=0.1", expected: Gzip},
+		{acceptEncoding: "*, gzip;q=0", expected: Deflate},
+		{acceptEncoding: "gzip;q=0, deflate;q=0", expected: ""},
+		{acceptEncoding: "identity, br", expected: ""},
+		{acceptEncoding: "X-GZIP ; Q=0.8", expected: Gzip},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert.Equal(t, c.expected, Negotiate(c.acceptEncoding))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"key":"value"},`, 100)
+
+	cases := []struct {
+		acceptEncoding string
+		contentType    string
+		body           string
+		flush          bool
+		encoding       string
+	}{
+		{acceptEncoding: "gzip", contentType: "application/json", body: large, encoding: Gzip},
+		{acceptEncoding: "deflate", contentType: "application/json", body: large, encoding: Deflate},
+		{acceptEncoding: "", contentType: "application/json", body: large, encoding: ""},
+		{acceptEncoding: "gzip", contentType: "application/json", body: `{}`, encoding: ""},
+		{acceptEncoding: "gzip", contentType: "application/json", body: `{}`, flush: true, encoding: Gzip},
+		{acceptEncoding: "gzip", contentType: "image/png", body: large, encoding: ""},
+		{acceptEncoding: "gzip", contentType: "", body: large, encoding: Gzip},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			handler := NewMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c.contentType != "" {
+					w.Header().Set(header.ContentType, c.contentType)
+				}
+				w.Header().Set(header.ContentLength, fmt.Sprint(len(c.body)))
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, c.body)
+				if c.flush {
+					w.(http.Flusher).Flush()
+				}
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if c.acceptEncoding != "" {
+				r.Header.Set(header.AcceptEncoding, c.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(http.StatusCreated, w.Code)
+			assert.Equal(header.AcceptEncoding, w.Header().Get(header.Vary))
+			assert.Equal(c.encoding, w.Header().Get(header.ContentEncoding))
+
+			var body io.Reader = w.Body
+			switch c.encoding {
+			case Gzip:
+				gr, err := gzip.NewReader(body)
+				require.NoError(err)
+				body = gr
+				assert.Empty(w.Header().Get(header.ContentLength))
+			case Deflate:
+				zr, err := zlib.NewReader(body)
+				require.NoError(err)
+				body = zr
+				assert.Empty(w.Header().Get(header.ContentLength))
+			default:
+				assert.Equal(fmt.Sprint(len(c.body)), w.Header().Get(header.ContentLength))
+			}
+			data, err := io.ReadAll(body)
+			require.NoError(err)
+			assert.Equal(c.body, string(data))
+		})
+	}
+}
+
+func TestMiddlewareNoBody(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := NewMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(header.AcceptEncoding, "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(http.StatusNoContent, w.Code)
+	assert.Empty(w.Header().Get(header.ContentEncoding))
+	assert.Equal(0, w.Body.Len())
+}
+
+func TestCustomMiddlewareLevel(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	body := strings.Repeat("a", 2048)
+	handler := NewCustomMiddleware(Config{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(header.AcceptEncoding, Gzip)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// The zero level compresses using the default level
+	assert.Equal(Gzip, w.Header().Get(header.ContentEncoding))
+	assert.Less(w.Body.Len(), len(body))
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(err)
+	data, err := io.ReadAll(gr)
+	require.NoError(err)
+	assert.Equal(body, string(data))
+
+	assert.Panics(func() {
+		NewCustomMiddleware(Config{Level: 42})
+	})
+}
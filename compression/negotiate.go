@@ -0,0 +1,61 @@
+package compression
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Supported content codings, in order of preference when q-values are equal
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+)
+
+var supportedEncodings = []string{Gzip, Deflate}
+
+// Negotiate returns the preferred content coding among the supported ones, according to the value of an
+// Accept-Encoding header.
+// An empty string is returned if none of the supported codings is acceptable.
+func Negotiate(acceptEncoding string) string {
+	qvalues := map[string]float64{}
+	wildcard := -1.0
+
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(item, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		switch coding {
+		case "*":
+			wildcard = q
+		case "x-gzip":
+			qvalues[Gzip] = q
+		default:
+			qvalues[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range supportedEncodings {
+		q, ok := qvalues[coding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,291 @@
+// Package har captures HTTP exchanges into the HTTP Archive (HAR) 1.2 format, which can be loaded into browser
+// developer tools.
+package har
+
+import (
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/morelj/httptools/header"
+)
+
+// Redacted is the value replacing redacted headers and cookies in HAR entries
+const Redacted = "<redacted>"
+
+// DefaultRedactHeaders are the headers redacted by default.
+var DefaultRedactHeaders = []string{
+	header.Authorization,
+	header.ProxyAuthorization,
+	header.Cookie,
+	header.SetCookie,
+	"X-Api-Key",
+}
+
+// HAR is the root of an HTTP Archive document.
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log is the log of an HTTP Archive.
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator describes the application which created the log.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is a single HTTP exchange.
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"`
+	Request         Request   `json:"request"`
+	Response        Response  `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         Timings   `json:"timings"`
+	ServerIPAddress string    `json:"serverIPAddress,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+}
+
+// Request is the request of an Entry.
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// Response is the response of an Entry.
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// NameValue is a name/value pair, used for headers and query parameters.
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Cookie is a cookie sent with a request, or set by a response.
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+// PostData is the body of a request.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Content is the body of a response.
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Timings are the durations, in milliseconds, of the phases of an exchange.
+// -1 means the phase does not apply.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// milliseconds converts d into milliseconds, as used by HAR
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// newRequest returns the HAR representation of r.
+// body is the captured body, truncated if its size exceeds the captured data.
+// The values of the headers listed in redact, and of the cookies if the Cookie header is listed, are redacted.
+func newRequest(r *http.Request, body []byte, size int64, redact []string) Request {
+	req := Request{
+		Method:      r.Method,
+		URL:         requestURL(r),
+		HTTPVersion: r.Proto,
+		Cookies:     []Cookie{},
+		Headers:     nameValues(redactHeader(r.Header, redact)),
+		QueryString: nameValues(r.URL.Query()),
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+	if req.HTTPVersion == "" {
+		req.HTTPVersion = "HTTP/1.1"
+	}
+	if r.Host != "" {
+		req.Headers = append([]NameValue{{Name: header.Host, Value: r.Host}}, req.Headers...)
+	}
+	for _, c := range r.Cookies() {
+		cookie := Cookie{Name: c.Name, Value: c.Value}
+		if isRedacted(header.Cookie, redact) {
+			cookie.Value = Redacted
+		}
+		req.Cookies = append(req.Cookies, cookie)
+	}
+	if size > 0 {
+		text, encoding := bodyText(body)
+		comments := []string{}
+		if encoding != "" {
+			// postData has no encoding field, the encoding is noted in the comment
+			comments = append(comments, encoding)
+		}
+		if comment := truncatedComment(body, size); comment != "" {
+			comments = append(comments, comment)
+		}
+		req.PostData = &PostData{
+			MimeType: r.Header.Get(header.ContentType),
+			Text:     text,
+			Comment:  strings.Join(comments, ", "),
+		}
+	}
+	return req
+}
+
+// newResponse returns the HAR representation of a response.
+// The values of the headers listed in redact, and of the cookies if the Set-Cookie header is listed, are redacted.
+func newResponse(proto string, statusCode int, h http.Header, body []byte, size int64, redact []string) Response {
+	resp := Response{
+		Status:      statusCode,
+		StatusText:  http.StatusText(statusCode),
+		HTTPVersion: proto,
+		Cookies:     []Cookie{},
+		Headers:     nameValues(redactHeader(h, redact)),
+		RedirectURL: h.Get(header.Location),
+		HeadersSize: -1,
+		BodySize:    size,
+		Content: Content{
+			Size:     size,
+			MimeType: h.Get(header.ContentType),
+			Comment:  truncatedComment(body, size),
+		},
+	}
+	if resp.HTTPVersion == "" {
+		resp.HTTPVersion = "HTTP/1.1"
+	}
+	resp.Content.Text, resp.Content.Encoding = bodyText(body)
+
+	for _, c := range (&http.Response{Header: h}).Cookies() {
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if isRedacted(header.SetCookie, redact) {
+			cookie.Value = Redacted
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		resp.Cookies = append(resp.Cookies, cookie)
+	}
+	return resp
+}
+
+// requestURL returns the absolute URL of r
+func requestURL(r *http.Request) string {
+	if r.URL.IsAbs() {
+		return r.URL.String()
+	}
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// redactHeader returns a copy of h, where the values of the headers listed in redact are replaced by
+// Redacted
+func redactHeader(h http.Header, redact []string) http.Header {
+	h = h.Clone()
+	for _, key := range redact {
+		if values := h.Values(key); len(values) > 0 {
+			h.Set(key, Redacted)
+		}
+	}
+	return h
+}
+
+// isRedacted returns true if the header key is listed in redact
+func isRedacted(key string, redact []string) bool {
+	for _, k := range redact {
+		if http.CanonicalHeaderKey(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// nameValues converts headers or query parameters into name/value pairs, sorted by name
+func nameValues(values map[string][]string) []NameValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := []NameValue{}
+	for _, name := range names {
+		for _, v := range values[name] {
+			res = append(res, NameValue{Name: name, Value: v})
+		}
+	}
+	return res
+}
+
+// bodyText returns the text of body, along with its encoding if it's not valid UTF-8
+func bodyText(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// truncatedComment returns a comment if the captured body is smaller than the actual body
+func truncatedComment(body []byte, size int64) string {
+	if int64(len(body)) < size {
+		return "truncated"
+	}
+	return ""
+}
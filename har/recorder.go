@@ -0,0 +1,344 @@
+package har
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+)
+
+// Config is the configuration of a Recorder.
+type Config struct {
+	// Capacity is the maximum number of entries kept by the Recorder. The oldest entries are dropped first.
+	Capacity int
+
+	// MaxBodySize is the maximum number of bytes captured from each request and response body.
+	MaxBodySize int
+
+	// Creator identifies the application in the exported HAR.
+	Creator Creator
+
+	// RedactHeaders lists the request and response headers whose value is replaced by Redacted.
+	// Cookies are redacted as well when the Cookie or Set-Cookie header is listed.
+	// If nil, DefaultRedactHeaders is used.
+	RedactHeaders []string
+
+	// Authorize is called before serving the HAR file by ServeHTTP. It returns nil to grant access, or an error
+	// otherwise. If the error is an httperror.Error, its status code is used, otherwise 403 Forbidden is returned.
+	// If it is nil, all requests are denied.
+	Authorize func(r *http.Request) error
+}
+
+// DefaultConfig is the configuration used by NewRecorder.
+var DefaultConfig = Config{
+	Capacity:    100,
+	MaxBodySize: 64 << 10,
+	Creator: Creator{
+		Name:    "httptools",
+		Version: "1.0",
+	},
+}
+
+// Recorder captures HTTP exchanges into an in-memory ring buffer of HAR entries.
+//
+// Server exchanges are captured by the middleware returned by Middleware, client exchanges by the transport
+// returned by Transport. Recorder is also an http.Handler serving the captured entries as a HAR file, to the requests
+// granted by Config.Authorize.
+type Recorder struct {
+	cfg Config
+
+	mu      sync.Mutex
+	entries []Entry
+	next    int // Index of the next entry to write in the ring buffer
+	full    bool
+}
+
+// NewRecorder returns a new Recorder.
+// Calling NewRecorder() is equivalent to calling NewCustomRecorder(DefaultConfig)
+func NewRecorder() *Recorder {
+	return NewCustomRecorder(DefaultConfig)
+}
+
+// NewCustomRecorder returns a new Recorder, configured with cfg.
+func NewCustomRecorder(cfg Config) *Recorder {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = DefaultConfig.Capacity
+	}
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = DefaultRedactHeaders
+	}
+	return &Recorder{
+		cfg:     cfg,
+		entries: make([]Entry, cfg.Capacity),
+	}
+}
+
+// Add adds an entry to the Recorder, dropping the oldest one if the Recorder is full
+func (rec *Recorder) Add(entry Entry) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.entries[rec.next] = entry
+	rec.next = (rec.next + 1) % len(rec.entries)
+	if rec.next == 0 {
+		rec.full = true
+	}
+}
+
+// Entries returns the captured entries, from the oldest to the most recent
+func (rec *Recorder) Entries() []Entry {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if !rec.full {
+		return append([]Entry{}, rec.entries[:rec.next]...)
+	}
+	return append(append([]Entry{}, rec.entries[rec.next:]...), rec.entries[:rec.next]...)
+}
+
+// Reset removes all the captured entries
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.entries = make([]Entry, len(rec.entries))
+	rec.next = 0
+	rec.full = false
+}
+
+// HAR returns the captured entries as an HTTP Archive
+func (rec *Recorder) HAR() HAR {
+	return HAR{
+		Log: Log{
+			Version: "1.2",
+			Creator: rec.cfg.Creator,
+			Entries: rec.Entries(),
+		},
+	}
+}
+
+// ServeHTTP serves the captured entries as a downloadable HAR file, to the requests granted by Config.Authorize
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := rec.authorize(r); err != nil {
+		httperror.WriteTextErrorResponse(err, w)
+		return
+	}
+
+	data, err := json.MarshalIndent(rec.HAR(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(header.ContentType, "application/json")
+	w.Header().Set(header.ContentDisposition, fmt.Sprintf(`attachment; filename="%s.har"`, time.Now().UTC().Format("20060102T150405Z")))
+	w.Write(data)
+}
+
+// authorize calls the Authorize function and converts its error to an httperror.Error
+func (rec *Recorder) authorize(r *http.Request) httperror.Error {
+	if rec.cfg.Authorize == nil {
+		return httperror.New(http.StatusForbidden, "Forbidden")
+	}
+	err := rec.cfg.Authorize(r)
+	if err == nil {
+		return nil
+	}
+	var httpErr httperror.Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return httperror.NewWithError(err, http.StatusForbidden, "Forbidden")
+}
+
+// Middleware returns a middleware capturing the exchanges of subsequent handlers
+func (rec *Recorder) Middleware() mux.MiddlewareFunc {
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			reqBody := &capture{limit: rec.cfg.MaxBodySize}
+			if r.Body != nil && r.Body != http.NoBody {
+				reqBody.rc = r.Body
+				r.Body = reqBody
+			}
+			cw := &captureWriter{
+				ResponseWriter: w,
+				body:           capture{limit: rec.cfg.MaxBodySize},
+			}
+
+			defer func() {
+				end := time.Now()
+				if cw.statusCode == 0 {
+					cw.statusCode = http.StatusOK
+				}
+				if cw.firstByte.IsZero() {
+					cw.firstByte = end
+				}
+				rec.Add(Entry{
+					StartedDateTime: start,
+					Time:            milliseconds(end.Sub(start)),
+					Request:         newRequest(r, reqBody.data, reqBody.size, rec.cfg.RedactHeaders),
+					Response:        newResponse(r.Proto, cw.statusCode, w.Header(), cw.body.data, cw.body.size, rec.cfg.RedactHeaders),
+					Timings: Timings{
+						Blocked: -1,
+						DNS:     -1,
+						Connect: -1,
+						SSL:     -1,
+						Wait:    milliseconds(cw.firstByte.Sub(start)),
+						Receive: milliseconds(end.Sub(cw.firstByte)),
+					},
+				})
+			}()
+
+			next.ServeHTTP(cw, r)
+		})
+	})
+}
+
+// Transport returns an http.RoundTripper capturing the exchanges sent using next.
+// If next is nil, http.DefaultTransport is used.
+// Entries are added once the response body has been read entirely or closed.
+func (rec *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		var wroteRequest, firstByte time.Time
+		trace := &httptrace.ClientTrace{
+			WroteRequest:         func(httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+			GotFirstResponseByte: func() { firstByte = time.Now() },
+		}
+		traced := r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+
+		reqBody := &capture{limit: rec.cfg.MaxBodySize}
+		if r.Body != nil && r.Body != http.NoBody {
+			reqBody.rc = r.Body
+			traced.Body = reqBody
+		}
+
+		resp, err := next.RoundTrip(traced)
+		if err != nil {
+			return nil, err
+		}
+
+		respBody := &capture{limit: rec.cfg.MaxBodySize, rc: resp.Body}
+		respBody.done = func() {
+			end := time.Now()
+			if wroteRequest.IsZero() {
+				wroteRequest = start
+			}
+			if firstByte.IsZero() {
+				firstByte = wroteRequest
+			}
+			rec.Add(Entry{
+				StartedDateTime: start,
+				Time:            milliseconds(end.Sub(start)),
+				Request:         newRequest(r, reqBody.data, reqBody.size, rec.cfg.RedactHeaders),
+				Response:        newResponse(resp.Proto, resp.StatusCode, resp.Header, respBody.data, respBody.size, rec.cfg.RedactHeaders),
+				Timings: Timings{
+					Blocked: -1,
+					DNS:     -1,
+					Connect: -1,
+					SSL:     -1,
+					Send:    milliseconds(wroteRequest.Sub(start)),
+					Wait:    milliseconds(firstByte.Sub(wroteRequest)),
+					Receive: milliseconds(end.Sub(firstByte)),
+				},
+			})
+		}
+		resp.Body = respBody
+		return resp, nil
+	})
+}
+
+// roundTripperFunc is a function implementing http.RoundTripper
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// capture is a ReadCloser keeping the first bytes read from rc.
+// done is called once, when rc has been read entirely or closed.
+type capture struct {
+	rc    io.ReadCloser
+	limit int
+	data  []byte
+	size  int64
+	done  func()
+	once  sync.Once
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	c.write(p[:n])
+	if err == io.EOF {
+		c.finish()
+	}
+	return n, err
+}
+
+func (c *capture) Close() error {
+	err := c.rc.Close()
+	c.finish()
+	return err
+}
+
+func (c *capture) write(p []byte) {
+	c.size += int64(len(p))
+	if remaining := c.limit - len(c.data); remaining > 0 {
+		c.data = append(c.data, p[:min(len(p), remaining)]...)
+	}
+}
+
+func (c *capture) finish() {
+	c.once.Do(func() {
+		if c.done != nil {
+			c.done()
+		}
+	})
+}
+
+// captureWriter is a ResponseWriter capturing the status code and the body of the response
+type captureWriter struct {
+	http.ResponseWriter
+	statusCode int
+	firstByte  time.Time
+	body       capture
+}
+
+func (w *captureWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+		w.firstByte = time.Now()
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.body.write(p[:n])
+	return n, err
+}
+
+// Flush implements http.Flusher
+func (w *captureWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by http.ResponseController
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,124 @@
+package har
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg := DefaultConfig
+	cfg.Capacity = 2
+	cfg.MaxBodySize = 5
+	cfg.Authorize = func(r *http.Request) error {
+		if !strings.HasPrefix(r.RemoteAddr, "127.0.0.1:") {
+			return errors.New("not a loopback address")
+		}
+		return nil
+	}
+	rec := NewCustomRecorder(cfg)
+
+	server := httptest.NewServer(rec.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret", HttpOnly: true})
+		w.Header().Set(header.ContentType, "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "received "+string(data))
+	})))
+	defer server.Close()
+
+	client := &http.Client{Transport: rec.Transport(nil)}
+	for _, body := range []string{"first", "second"} {
+		r, _ := http.NewRequest(http.MethodPost, server.URL+"/path?q="+body, strings.NewReader(body))
+		r.Header.Set(header.ContentType, "text/plain")
+		r.Header.Set(header.Authorization, "Bearer token")
+		r.AddCookie(&http.Cookie{Name: "session", Value: "secret"})
+		resp, err := client.Do(r)
+		require.NoError(err)
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal("received "+body, string(data))
+	}
+
+	// Each exchange is captured twice (server and client), only the last 2 entries are kept
+	entries := rec.Entries()
+	require.Len(entries, 2)
+	for _, entry := range entries {
+		assert.Equal(http.MethodPost, entry.Request.Method)
+		assert.Equal(server.URL+"/path?q=second", entry.Request.URL)
+		assert.Equal([]NameValue{{Name: "q", Value: "second"}}, entry.Request.QueryString)
+		assert.Equal(int64(6), entry.Request.BodySize)
+		require.NotNil(entry.Request.PostData)
+		assert.Equal("secon", entry.Request.PostData.Text)
+		assert.Equal("truncated", entry.Request.PostData.Comment)
+		assert.Contains(entry.Request.Headers, NameValue{Name: header.Authorization, Value: Redacted})
+		assert.Equal([]Cookie{{Name: "session", Value: Redacted}}, entry.Request.Cookies)
+
+		assert.Equal(http.StatusCreated, entry.Response.Status)
+		assert.Equal("Created", entry.Response.StatusText)
+		assert.Equal("recei", entry.Response.Content.Text)
+		assert.Equal(int64(len("received second")), entry.Response.Content.Size)
+		assert.Equal("text/plain", entry.Response.Content.MimeType)
+		require.Len(entry.Response.Cookies, 1)
+		assert.Equal("session", entry.Response.Cookies[0].Name)
+		assert.Equal(Redacted, entry.Response.Cookies[0].Value)
+		assert.True(entry.Response.Cookies[0].HTTPOnly)
+		assert.Contains(entry.Response.Headers, NameValue{Name: header.SetCookie, Value: Redacted})
+		assert.GreaterOrEqual(entry.Time, 0.0)
+	}
+
+	// Download the HAR file
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/debug/har", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	rec.ServeHTTP(w, r)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Header().Get(header.ContentDisposition), ".har")
+
+	var har HAR
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &har))
+	assert.Equal("1.2", har.Log.Version)
+	assert.Len(har.Log.Entries, 2)
+	assert.NotContains(w.Body.String(), "secret")
+	assert.NotContains(w.Body.String(), "Bearer token")
+
+	// Remote requests are denied
+	r = httptest.NewRequest(http.MethodGet, "/debug/har", nil)
+	r.RemoteAddr = "203.0.113.1:1234"
+	w = httptest.NewRecorder()
+	rec.ServeHTTP(w, r)
+	assert.Equal(http.StatusForbidden, w.Code)
+
+	rec.Reset()
+	assert.Empty(rec.Entries())
+}
+
+func TestRecorderDeniesByDefault(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewRecorder().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/har", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestNewRequestBinaryBody(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(header.ContentType, "application/octet-stream")
+	req := newRequest(r, []byte{0xff, 0x00}, 3, nil)
+	if assert.NotNil(req.PostData) {
+		assert.Equal("/wA=", req.PostData.Text)
+		assert.Equal("base64, truncated", req.PostData.Comment)
+		assert.Equal("application/octet-stream", req.PostData.MimeType)
+	}
+}
@@ -0,0 +1,165 @@
+package httpassert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/morelj/httptools/header"
+	"github.com/stretchr/testify/assert"
+)
+
+// Redacted is the value replacing redacted fields and headers in golden files
+const Redacted = "<redacted>"
+
+// updateGolden is namespaced, not to conflict with the -update flag commonly defined by tests
+var updateGolden = flag.Bool("httpassert.update", false, "update the golden files of httpassert")
+
+// GoldenConfig configures how responses are written to golden files.
+type GoldenConfig struct {
+	// Dir is the directory holding the golden files.
+	Dir string
+
+	// Headers lists the headers written to golden files.
+	Headers []string
+
+	// RedactHeaders lists the headers whose value is replaced by Redacted.
+	RedactHeaders []string
+
+	// RedactFields lists dotted paths (e.g. "id" or "items.*.createdAt") of JSON fields whose value is replaced by
+	// Redacted. The "*" segment matches all the members of an object, or all the elements of an array.
+	RedactFields []string
+
+	// RedactPatterns are regular expressions whose matches are replaced by Redacted, in the whole golden file.
+	RedactPatterns []*regexp.Regexp
+}
+
+// DefaultGoldenConfig is the configuration used by Response.Golden.
+var DefaultGoldenConfig = GoldenConfig{
+	Dir:     "testdata",
+	Headers: []string{header.ContentType, header.Location},
+}
+
+// Golden asserts the response matches the golden file named name.
+// Calling Golden(name) is equivalent to calling CustomGolden(name, DefaultGoldenConfig)
+func (r *Response) Golden(name string) *Response {
+	r.t.Helper()
+	return r.CustomGolden(name, DefaultGoldenConfig)
+}
+
+// CustomGolden asserts the response matches the golden file named name, using the given configuration.
+//
+// The golden file holds the request line, the status, the selected headers and the body of the response.
+// JSON bodies are pretty-printed with sorted keys, so that differences are easy to review.
+//
+// When the tests are run with the -httpassert.update flag, golden files are written instead of being compared.
+func (r *Response) CustomGolden(name string, cfg GoldenConfig) *Response {
+	r.t.Helper()
+
+	actual := r.golden(cfg)
+	path := filepath.Join(cfg.Dir, name+".golden")
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Errorf("Failed to create golden file directory: %v", err)
+			return r
+		}
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			r.t.Errorf("Failed to write golden file: %v", err)
+		}
+		return r
+	}
+
+	expected, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		r.t.Errorf("Golden file %s does not exist, run the tests with -httpassert.update to create it", path)
+		return r
+	} else if err != nil {
+		r.t.Errorf("Failed to read golden file: %v", err)
+		return r
+	}
+	assert.Equal(r.t, string(expected), string(actual), "Response does not match golden file %s, run the tests with -httpassert.update to update it", path)
+	return r
+}
+
+// golden returns the content of the golden file for the response
+func (r *Response) golden(cfg GoldenConfig) []byte {
+	var buf bytes.Buffer
+
+	if r.Request != nil {
+		fmt.Fprintf(&buf, "%s %s\n\n", r.Request.Method, r.Request.URL.RequestURI())
+	}
+	fmt.Fprintf(&buf, "%d %s\n", r.Recorder.Code, http.StatusText(r.Recorder.Code))
+
+	headers := make([]string, 0, len(cfg.Headers))
+	for _, key := range cfg.Headers {
+		headers = append(headers, http.CanonicalHeaderKey(key))
+	}
+	sort.Strings(headers)
+	for _, key := range headers {
+		redact := containsFold(cfg.RedactHeaders, key)
+		for _, value := range r.Recorder.Header().Values(key) {
+			if redact {
+				value = Redacted
+			}
+			fmt.Fprintf(&buf, "%s: %s\n", key, value)
+		}
+	}
+
+	if body := r.goldenBody(cfg); len(body) > 0 {
+		buf.WriteByte('\n')
+		buf.Write(body)
+		if !bytes.HasSuffix(body, []byte{'\n'}) {
+			buf.WriteByte('\n')
+		}
+	}
+
+	data := buf.Bytes()
+	for _, re := range cfg.RedactPatterns {
+		data = re.ReplaceAllLiteral(data, []byte(Redacted))
+	}
+	return data
+}
+
+// goldenBody returns the normalized body of the response
+func (r *Response) goldenBody(cfg GoldenConfig) []byte {
+	body := r.Recorder.Body.Bytes()
+
+	if !isJSON(r.Recorder.Header().Get(header.ContentType)) {
+		return body
+	}
+	// Numbers are kept as written, so that large integers don't lose precision
+	v, err := decodeJSON(body)
+	if err != nil {
+		return body
+	}
+	for _, path := range cfg.RedactFields {
+		replace(v, path, Redacted)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return body
+	}
+	return buf.Bytes()
+}
+
+// containsFold returns true if values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
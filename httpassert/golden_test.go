@@ -0,0 +1,57 @@
+package httpassert
+
+import (
+	"flag"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/request"
+	"github.com/morelj/httptools/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests commonly define their own -update flag, which must not conflict with the flag of httpassert
+var _ = flag.Bool("update", false, "update test files")
+
+func TestGolden(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.NewBuilder().
+			WithStatus(http.StatusCreated).
+			WithHeader("Location", "/items/"+r.URL.Query().Get("id")).
+			WithHeader("X-Request-Id", time.Now().String()).
+			WithJSONBody(map[string]interface{}{
+				"id":        r.URL.Query().Get("id"),
+				"createdAt": time.Now(),
+				"tags": []map[string]string{
+					{"name": "a", "token": time.Now().String()},
+				},
+				"link": "/items/" + r.URL.Query().Get("id") + "?session=" + time.Now().String(),
+			}).
+			MustWrite(w)
+	})
+
+	cfg := DefaultGoldenConfig
+	cfg.Headers = append(cfg.Headers, "X-Request-Id")
+	cfg.RedactHeaders = []string{"X-Request-Id"}
+	cfg.RedactFields = []string{"createdAt", "tags.*.token"}
+	cfg.RedactPatterns = []*regexp.Regexp{regexp.MustCompile(`session=[^"]*`)}
+
+	r := request.NewTestBuilder(http.MethodPost, "/items", nil).WithQuery("id", "42").Request()
+	Serve(t, handler, r).
+		Status(http.StatusCreated).
+		CustomGolden("create_item", cfg)
+}
+
+func TestGoldenBodyLargeNumbers(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(header.ContentType, "application/json")
+		w.Write([]byte(`{"ratio":0.50,"id":9007199254740993}`))
+	})
+
+	r := request.NewTestBuilder(http.MethodGet, "/items", nil).Request()
+	body := Serve(t, handler, r).goldenBody(DefaultGoldenConfig)
+	assert.Equal(t, "{\n  \"id\": 9007199254740993,\n  \"ratio\": 0.50\n}\n", string(body))
+}
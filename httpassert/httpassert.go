@@ -0,0 +1,248 @@
+// Package httpassert provides fluent assertions on the responses of HTTP handlers, for use in tests.
+package httpassert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morelj/httptools/header"
+	"github.com/stretchr/testify/assert"
+)
+
+// Response is the recorded response of a handler, providing fluent assertions.
+// Failed assertions are reported using the testing.TB, without stopping the test.
+type Response struct {
+	t testing.TB
+
+	// Request is the request which has been served
+	Request *http.Request
+
+	// Recorder holds the recorded response
+	Recorder *httptest.ResponseRecorder
+}
+
+// Serve runs handler against r, and returns the recorded Response.
+// The handler is wrapped by the given middlewares, the first one being the outermost.
+func Serve(t testing.TB, handler http.Handler, r *http.Request, middlewares ...mux.MiddlewareFunc) *Response {
+	t.Helper()
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return &Response{
+		t:        t,
+		Request:  r,
+		Recorder: w,
+	}
+}
+
+// Status asserts the status code of the response
+func (r *Response) Status(statusCode int) *Response {
+	r.t.Helper()
+	assert.Equal(r.t, statusCode, r.Recorder.Code, "Unexpected status code, body:\n%s", r.Recorder.Body.String())
+	return r
+}
+
+// Header asserts the value of a header of the response
+func (r *Response) Header(key, value string) *Response {
+	r.t.Helper()
+	assert.Equal(r.t, value, r.Recorder.Header().Get(key), "Unexpected value for header %s", key)
+	return r
+}
+
+// NoHeader asserts the response doesn't have the given header
+func (r *Response) NoHeader(key string) *Response {
+	r.t.Helper()
+	assert.Empty(r.t, r.Recorder.Header().Values(key), "Unexpected header %s", key)
+	return r
+}
+
+// Body asserts the body of the response
+func (r *Response) Body(expected string) *Response {
+	r.t.Helper()
+	assert.Equal(r.t, expected, r.Recorder.Body.String(), "Unexpected body")
+	return r
+}
+
+// JSON asserts the body of the response is equal to expected, once both are serialized to JSON.
+// expected can be any value which can be serialized to JSON, or a json.RawMessage or []byte holding raw JSON.
+// A string is compared as a JSON string, and is never decoded.
+//
+// ignoredFields are dotted paths (e.g. "id" or "items.*.createdAt") of fields removed from both values before
+// comparison. The "*" segment matches all the members of an object, or all the elements of an array.
+func (r *Response) JSON(expected interface{}, ignoredFields ...string) *Response {
+	r.t.Helper()
+
+	actual, ok := r.decodeBody()
+	if !ok {
+		return r
+	}
+	exp, err := normalize(expected)
+	if err != nil {
+		r.t.Errorf("Invalid expected value: %v", err)
+		return r
+	}
+	for _, path := range ignoredFields {
+		remove(actual, path)
+		remove(exp, path)
+	}
+	assertJSONEqual(r.t, exp, actual)
+	return r
+}
+
+// JSONSubset asserts the body of the response contains expected, once both are serialized to JSON.
+// Object members which are not in expected are ignored, recursively.
+func (r *Response) JSONSubset(expected interface{}) *Response {
+	r.t.Helper()
+
+	actual, ok := r.decodeBody()
+	if !ok {
+		return r
+	}
+	exp, err := normalize(expected)
+	if err != nil {
+		r.t.Errorf("Invalid expected value: %v", err)
+		return r
+	}
+	assertJSONEqual(r.t, exp, subset(exp, actual))
+	return r
+}
+
+// JSONPath asserts the value at path in the JSON body of the response.
+// path is a dotted path, where object members are selected by name and array elements by index
+// (e.g. "items.0.name"). An empty path selects the whole body.
+func (r *Response) JSONPath(path string, expected interface{}) *Response {
+	r.t.Helper()
+
+	body, ok := r.decodeBody()
+	if !ok {
+		return r
+	}
+	actual, found := lookup(body, path)
+	if !found {
+		r.t.Errorf("No value at path %q in body:\n%s", path, r.Recorder.Body.String())
+		return r
+	}
+	exp, err := normalize(expected)
+	if err != nil {
+		r.t.Errorf("Invalid expected value: %v", err)
+		return r
+	}
+	assertJSONEqual(r.t, exp, actual, "Unexpected value at path %q", path)
+	return r
+}
+
+// Error asserts the response is an error response, as written by the ErrorResponseWriterFuncs of the httperror
+// package: the status code must match, and the body must hold the message, either in JSON or in plain text.
+func (r *Response) Error(statusCode int, message string) *Response {
+	r.t.Helper()
+
+	r.Status(statusCode)
+	if isJSON(r.Recorder.Header().Get(header.ContentType)) {
+		return r.JSONSubset(map[string]interface{}{
+			"message": message,
+		})
+	}
+	return r.Body(message)
+}
+
+// decodeBody decodes the JSON body of the response
+func (r *Response) decodeBody() (interface{}, bool) {
+	r.t.Helper()
+
+	v, err := decodeJSON(r.Recorder.Body.Bytes())
+	if err != nil {
+		r.t.Errorf("Body is not valid JSON: %v\n%s", err, r.Recorder.Body.String())
+		return nil, false
+	}
+	return canonicalNumbers(v), true
+}
+
+// normalize converts v to the generic value obtained by decoding its JSON representation.
+// json.RawMessage and []byte values hold raw JSON, and are decoded as is.
+func normalize(v interface{}) (interface{}, error) {
+	var data []byte
+	switch v := v.(type) {
+	case json.RawMessage:
+		data = v
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := decodeJSON(data)
+	return canonicalNumbers(res), err
+}
+
+// decodeJSON decodes data into a generic value. Numbers are decoded as json.Number, so that large integers keep
+// their precision.
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return v, nil
+}
+
+// canonicalNumbers replaces, in place, the json.Numbers of v by their canonical representation, so that equal
+// numbers written differently (e.g. 1.0 and 1) compare equal. Integers are kept as is, to keep their precision.
+func canonicalNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if strings.ContainsAny(string(v), ".eE") {
+			if f, err := v.Float64(); err == nil {
+				return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+			}
+		}
+		return v
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = canonicalNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = canonicalNumbers(child)
+		}
+	}
+	return v
+}
+
+// assertJSONEqual compares the indented JSON representations of expected and actual, for readable diffs
+func assertJSONEqual(t testing.TB, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+	t.Helper()
+
+	exp, _ := json.MarshalIndent(expected, "", "  ")
+	act, _ := json.MarshalIndent(actual, "", "  ")
+	if len(msgAndArgs) == 0 {
+		msgAndArgs = []interface{}{"Unexpected JSON body"}
+	}
+	return assert.Equal(t, string(exp), string(act), msgAndArgs...)
+}
+
+// isJSON returns true if contentType is a JSON media type
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
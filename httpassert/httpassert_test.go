@@ -0,0 +1,102 @@
+package httpassert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morelj/httptools/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingT records the failures of assertions
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func jsonHandler(status int, body interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.NewBuilder().WithStatus(status).WithJSONBody(body).MustWrite(w)
+	})
+}
+
+func TestAssertions(t *testing.T) {
+	body := map[string]interface{}{
+		"id":    "123",
+		"name":  "item",
+		"count": json.RawMessage("9007199254740993"),
+		"ratio": json.RawMessage("0.5"),
+		"tags": []interface{}{
+			map[string]interface{}{"name": "a", "createdAt": "2021-01-01"},
+			map[string]interface{}{"name": "b", "createdAt": "2021-01-02"},
+		},
+	}
+	handler := jsonHandler(http.StatusOK, body)
+
+	cases := []struct {
+		assert func(r *Response)
+		failed bool
+	}{
+		{assert: func(r *Response) { r.Status(http.StatusOK) }},
+		{assert: func(r *Response) { r.Status(http.StatusCreated) }, failed: true},
+		{assert: func(r *Response) { r.Header("Content-Type", "application/json") }},
+		{assert: func(r *Response) { r.NoHeader("Location") }},
+		{assert: func(r *Response) { r.JSON(body) }},
+		{assert: func(r *Response) {
+			r.JSON(json.RawMessage(`{"id":"456","name":"item","count":9007199254740993,"ratio":0.5,"tags":[{"name":"a"},{"name":"b"}]}`), "id", "tags.*.createdAt")
+		}},
+		{assert: func(r *Response) { r.JSON(json.RawMessage(`{"name":"item"}`)) }, failed: true},
+		{assert: func(r *Response) { r.JSONSubset(json.RawMessage(`{"name":"item","tags":[{"name":"a"},{"name":"b"}]}`)) }},
+		{assert: func(r *Response) { r.JSONSubset(json.RawMessage(`{"name":"other"}`)) }, failed: true},
+		{assert: func(r *Response) { r.JSONPath("tags.1.name", "b") }},
+		{assert: func(r *Response) { r.JSONPath("tags.2.name", "b") }, failed: true},
+		{assert: func(r *Response) { r.JSONPath("id", "123") }},
+		{assert: func(r *Response) { r.JSONPath("id", 123) }, failed: true},
+		{assert: func(r *Response) { r.JSONPath("name", []byte(`"item"`)) }},
+		{assert: func(r *Response) { r.JSON(`{"id":"123"}`) }, failed: true},
+		{assert: func(r *Response) { r.JSONPath("count", 9007199254740993) }},
+		{assert: func(r *Response) { r.JSONPath("count", 9007199254740992) }, failed: true},
+		{assert: func(r *Response) { r.JSONPath("ratio", json.RawMessage("0.50")) }},
+		{assert: func(r *Response) { r.JSONPath("ratio", 0.5) }},
+		{assert: func(r *Response) { r.JSONSubset(map[string]interface{}{"count": 9007199254740992}) }, failed: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			rt := &recordingT{TB: t}
+			c.assert(Serve(rt, handler, httptest.NewRequest(http.MethodGet, "/", nil)))
+			if c.failed {
+				assert.NotEmpty(t, rt.failures)
+			} else {
+				assert.Empty(t, rt.failures)
+			}
+		})
+	}
+}
+
+func TestServeMiddlewares(t *testing.T) {
+	var order []string
+	middleware := func(name string) mux.MiddlewareFunc {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	Serve(t, jsonHandler(http.StatusNotFound, map[string]interface{}{"message": "not found", "code": 404}),
+		httptest.NewRequest(http.MethodGet, "/", nil), middleware("outer"), middleware("inner")).
+		Error(http.StatusNotFound, "not found")
+	assert.Equal(t, []string{"outer", "inner"}, order)
+}
@@ -0,0 +1,127 @@
+package httpassert
+
+import (
+	"strconv"
+	"strings"
+)
+
+// splitPath splits a dotted path, such as "items.0.name", into its segments
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// lookup returns the value at path in v, which is a value decoded from JSON.
+// Object members are selected by name, array elements by index.
+func lookup(v interface{}, path string) (interface{}, bool) {
+	for _, segment := range splitPath(path) {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			v = child
+
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// remove removes the value at path from v, which is a value decoded from JSON.
+// The "*" segment matches all the members of an object, or all the elements of an array.
+// Array elements can't be removed without shifting the others, they are nulled instead.
+func remove(v interface{}, path string) {
+	update(v, splitPath(path), func(interface{}) (interface{}, bool) {
+		return nil, false
+	})
+}
+
+// replace replaces the value at path in v, which is a value decoded from JSON, by value.
+// The "*" segment matches all the members of an object, or all the elements of an array.
+func replace(v interface{}, path string, value interface{}) {
+	update(v, splitPath(path), func(interface{}) (interface{}, bool) {
+		return value, true
+	})
+}
+
+// update calls fn on the values matched by segments, and replaces them with the returned value.
+// If fn returns false, object members are deleted and array elements are nulled.
+func update(v interface{}, segments []string, fn func(old interface{}) (interface{}, bool)) {
+	if len(segments) == 0 {
+		return
+	}
+	segment, last := segments[0], len(segments) == 1
+
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			if segment != "*" && segment != k {
+				continue
+			}
+			if !last {
+				update(child, segments[1:], fn)
+			} else if nv, keep := fn(child); keep {
+				node[k] = nv
+			} else {
+				delete(node, k)
+			}
+		}
+
+	case []interface{}:
+		for i, child := range node {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				continue
+			}
+			if !last {
+				update(child, segments[1:], fn)
+			} else {
+				node[i], _ = fn(child)
+			}
+		}
+	}
+}
+
+// subset returns a copy of actual restricted to the members present in expected, recursively.
+// Arrays are restricted element by element when they have the same length.
+func subset(expected, actual interface{}) interface{} {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return actual
+		}
+		res := make(map[string]interface{}, len(e))
+		for k, ev := range e {
+			if av, ok := a[k]; ok {
+				res[k] = subset(ev, av)
+			}
+		}
+		return res
+
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return actual
+		}
+		res := make([]interface{}, len(a))
+		for i := range a {
+			res[i] = subset(e[i], a[i])
+		}
+		return res
+
+	default:
+		return actual
+	}
+}
@@ -0,0 +1,180 @@
+//go:build httptools_dev
+
+package httperror
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/response"
+	"github.com/morelj/httptools/stack"
+)
+
+// DevMode is true when the package is built with the httptools_dev build tag, enabling the development error page of
+// NewDevMiddleware.
+const DevMode = true
+
+// devFilters are the filters applied to the stack shown on the development error page.
+// Unlike LogFilters, the frames of httptools are kept, as they may be part of the application.
+var devFilters = []stack.Filter{
+	stack.DropRuntime,
+	stack.DropStdlib,
+	stack.DropModules("github.com/gorilla/mux"),
+}
+
+// devSourceLoader loads the source snippets of the development error page
+var devSourceLoader = stack.NewSourceLoader()
+
+// NewDevMiddleware returns a middleware which will recover when subsequent handlers panics, as returned by
+// NewCustomContextMiddleware, except that the errors of requests accepting text/html are written as a detailed HTML
+// page, showing the error chain, the stack of the panic with source snippets, and the request.
+// Other requests are written using ew.
+//
+// The page is only rendered when the package is built with the httptools_dev build tag (go run -tags httptools_dev),
+// otherwise NewDevMiddleware is equivalent to NewCustomContextMiddleware. As the page discloses the internals of the
+// application, never use this tag to build production binaries.
+func NewDevMiddleware(ew ErrorResponseWriterFunc, wrap WrapperContextFunc, logger LoggerFunc) mux.MiddlewareFunc {
+	return newRecoveryMiddleware(func(r *http.Request, s stack.Stack) ErrorResponseWriterFunc {
+		if !strings.Contains(r.Header.Get(header.Accept), "text/html") {
+			return ew
+		}
+		return ErrorResponseWriterFunc(func(err Error, w http.ResponseWriter) error {
+			body, renderErr := renderDevPage(err, r, s)
+			if renderErr != nil {
+				return ew(err, w)
+			}
+			return response.NewBuilder().
+				WithStatus(err.StatusCode()).
+				WithHeaders(Header(err)).
+				WithHeader(header.ContentType, "text/html; charset=utf-8").
+				WithBody(body).
+				Write(w)
+		})
+	}, wrap, logger)
+}
+
+// devChainItem is an error of the chain shown on the development error page
+type devChainItem struct {
+	Type    string
+	Message string
+}
+
+// devHeader is a request header shown on the development error page
+type devHeader struct {
+	Name   string
+	Values []string
+}
+
+// renderDevPage renders the development error page
+func renderDevPage(err Error, r *http.Request, s stack.Stack) ([]byte, error) {
+	var chain []devChainItem
+	for e := error(err); e != nil; e = errors.Unwrap(e) {
+		chain = append(chain, devChainItem{
+			Type:    fmt.Sprintf("%T", e),
+			Message: e.Error(),
+		})
+	}
+
+	var headers []devHeader
+	for name, values := range r.Header {
+		headers = append(headers, devHeader{Name: name, Values: values})
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+
+	var vars []devHeader
+	for name, value := range mux.Vars(r) {
+		vars = append(vars, devHeader{Name: name, Values: []string{value}})
+	}
+	sort.Slice(vars, func(i, j int) bool {
+		return vars[i].Name < vars[j].Name
+	})
+
+	var elements []stack.Element
+	if filtered := s.TrimPanic().Filter(devFilters...).WithSnippets(devSourceLoader).ShortenPaths(); len(filtered.Goroutines) > 0 {
+		elements = filtered.Goroutines[0].Elements
+	}
+
+	var buf bytes.Buffer
+	renderErr := devPageTemplate.Execute(&buf, map[string]interface{}{
+		"Status":     err.StatusCode(),
+		"StatusText": http.StatusText(err.StatusCode()),
+		"Message":    err.Error(),
+		"Chain":      chain,
+		"Elements":   elements,
+		"Method":     r.Method,
+		"URL":        r.URL.String(),
+		"Headers":    headers,
+		"Vars":       vars,
+	})
+	return buf.Bytes(), renderErr
+}
+
+var devPageTemplate = template.Must(template.New("dev").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Status }} {{ .StatusText }}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+h1 { color: #b00020; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; }
+.current { background: #ffe0e0; font-weight: bold; }
+.source { color: #666; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ddd; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>{{ .Status }} {{ .StatusText }}</h1>
+<p>{{ .Message }}</p>
+
+<h2>Errors</h2>
+<table>
+{{- range .Chain }}
+<tr><td><code>{{ .Type }}</code></td><td>{{ .Message }}</td></tr>
+{{- end }}
+</table>
+
+<h2>Stack</h2>
+{{- range .Elements }}
+<h3><code>{{ .FuncName }}</code> <span class="source">{{ .File }}:{{ .Line }}</span></h3>
+{{- with .Snippet }}
+<pre>
+{{- $line := .Line }}
+{{- range .Lines }}
+<span{{ if eq .Number $line }} class="current"{{ end }}>{{ printf "%5d" .Number }}  {{ .Text }}</span>
+{{- end }}
+</pre>
+{{- end }}
+{{- else }}
+<p>No stack available.</p>
+{{- end }}
+
+<h2>Request</h2>
+<p><code>{{ .Method }} {{ .URL }}</code></p>
+{{- if .Vars }}
+<h3>Route variables</h3>
+<table>
+{{- range .Vars }}
+<tr><th>{{ .Name }}</th><td>{{ index .Values 0 }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+<h3>Headers</h3>
+<table>
+{{- range .Headers }}
+<tr><th>{{ .Name }}</th><td>{{ range $i, $v := .Values }}{{ if $i }}<br>{{ end }}{{ $v }}{{ end }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
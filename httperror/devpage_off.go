@@ -0,0 +1,15 @@
+//go:build !httptools_dev
+
+package httperror
+
+import "github.com/gorilla/mux"
+
+// DevMode is true when the package is built with the httptools_dev build tag, enabling the development error page of
+// NewDevMiddleware.
+const DevMode = false
+
+// NewDevMiddleware is equivalent to NewCustomContextMiddleware: the development error page is only available when the
+// package is built with the httptools_dev build tag.
+func NewDevMiddleware(ew ErrorResponseWriterFunc, wrap WrapperContextFunc, logger LoggerFunc) mux.MiddlewareFunc {
+	return NewCustomContextMiddleware(ew, wrap, logger)
+}
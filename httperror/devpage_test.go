@@ -0,0 +1,63 @@
+package httperror
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(NewDevMiddleware(WriteTextErrorResponse, WrapContext, NoOpLog))
+	router.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic(fmt.Errorf("loading item: %w", io.ErrUnexpectedEOF))
+	})
+
+	cases := []struct {
+		accept string
+		html   bool
+	}{
+		{accept: "text/html,application/xhtml+xml", html: DevMode},
+		{accept: "application/json", html: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			r := httptest.NewRequest(http.MethodGet, "/items/42?full=1", nil)
+			r.Header.Set("Accept", c.accept)
+			r.Header.Set("X-Request-Id", "<abc>")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(http.StatusInternalServerError, w.Code)
+			body := w.Body.String()
+			if !c.html {
+				assert.Equal("text/plain", w.Header().Get("Content-Type"))
+				assert.Equal("loading item: unexpected EOF", body)
+				return
+			}
+
+			assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Contains(body, "<h1>500 Internal Server Error</h1>")
+			// Error chain
+			assert.Contains(body, "<code>*fmt.wrapError</code>")
+			assert.Contains(body, "<code>*errors.errorString</code></td><td>unexpected EOF</td>")
+			// Stack, starting at the handler, with a snippet
+			assert.Contains(body, "<code>github.com/morelj/httptools/httperror.TestDevMiddleware.func1</code>")
+			assert.Contains(body, `class="current">`)
+			assert.Contains(body, "panic(fmt.Errorf(&#34;loading item: %w&#34;, io.ErrUnexpectedEOF))")
+			assert.NotContains(body, "gorilla/mux")
+			// Request
+			assert.Contains(body, "<code>GET /items/42?full=1</code>")
+			assert.Contains(body, "<tr><th>id</th><td>42</td></tr>")
+			assert.Contains(body, "<tr><th>X-Request-Id</th><td>&lt;abc&gt;</td></tr>")
+		})
+	}
+}
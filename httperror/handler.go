@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"runtime/debug"
 
 	"github.com/gorilla/mux"
 	"github.com/morelj/httptools/header"
@@ -17,7 +16,8 @@ import (
 type ErrorResponseWriterFunc func(err Error, w http.ResponseWriter) error
 
 // A LoggerFunc purpose is to log an error, after it has been wrapped.
-// The original HTTP request and the call stack of the current goroutine are also provided.
+// The original HTTP request and the call stack of the current goroutine are also provided. The stack is captured
+// without being formatted: use stack.Text to format it.
 type LoggerFunc func(r *http.Request, err Error, stack stack.Stack)
 
 // A WrapperFunc must wrap a panic (r) into an Error.
@@ -58,14 +58,21 @@ func NewCustomMiddleware(ew ErrorResponseWriterFunc, wrap WrapperFunc, logger Lo
 // - then wrap is called to obtain an Error from the value returned by recover
 // - finally the error is serialized using ew
 func NewCustomContextMiddleware(ew ErrorResponseWriterFunc, wrap WrapperContextFunc, logger LoggerFunc) mux.MiddlewareFunc {
+	return newRecoveryMiddleware(func(r *http.Request, stack stack.Stack) ErrorResponseWriterFunc {
+		return ew
+	}, wrap, logger)
+}
+
+// newRecoveryMiddleware returns a middleware which will recover when subsequent handlers panics.
+// writerFor returns the ErrorResponseWriterFunc used to write the error of the request r, given the stack of the
+// panic.
+func newRecoveryMiddleware(writerFor func(r *http.Request, stack stack.Stack) ErrorResponseWriterFunc, wrap WrapperContextFunc, logger LoggerFunc) mux.MiddlewareFunc {
 	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if rec := recover(); rec != nil {
-					stack, err := stack.Parse(debug.Stack())
-					if err != nil {
-						log.Errorf("Failed to parse stack: %v", err)
-					}
+					// Skip this deferred function
+					stack := stack.Capture(1)
 
 					// Wrap the error
 					wrappedErr := wrap(r.Context(), rec, stack)
@@ -73,7 +80,7 @@ func NewCustomContextMiddleware(ew ErrorResponseWriterFunc, wrap WrapperContextF
 					// Log the error
 					logger(r, wrappedErr, stack)
 
-					if err := ew(wrappedErr, w); err != nil {
+					if err := writerFor(r, stack)(wrappedErr, w); err != nil {
 						log.Errorf("Error writing error: %v\n", err)
 					}
 				}
@@ -86,7 +93,8 @@ func NewCustomContextMiddleware(ew ErrorResponseWriterFunc, wrap WrapperContextF
 
 // WrapContext is the default WrapperContextFunc.
 // - If r is an Error, it is returned as is
-// - If it is any other error type, it is wrapped into an Error with a 500 status code
+// - If it is any other error type, it is wrapped into an Error with a 500 status code, which unwraps to r so that
+// errors.Is and errors.As match r and the errors it wraps
 // - If it is any other value, it returns a 500 Error with an error message
 func WrapContext(ctx context.Context, r any, stack stack.Stack) Error {
 	switch r := r.(type) {
@@ -97,6 +105,7 @@ func WrapContext(ctx context.Context, r any, stack stack.Stack) Error {
 		return httpError{
 			Message: r.Error(),
 			Code:    http.StatusInternalServerError,
+			wrapped: r,
 		}
 
 	default:
@@ -109,17 +118,26 @@ func WrapContext(ctx context.Context, r any, stack stack.Stack) Error {
 
 // Wrap is the default WrapperFunc.
 // - If r is an Error, it is returned as is
-// - If it is any other error type, it is wrapped into an Error with a 500 status code
+// - If it is any other error type, it is wrapped into an Error with a 500 status code, which unwraps to r
 // - If it is any other value, it returns a 500 Error with an error message
 func Wrap(r interface{}, stack stack.Stack) Error {
 	return WrapContext(context.Background(), r, stack)
 }
 
+// LogFilters are the filters applied by Log to the stack, once trimmed above the panic site.
+// By default, the frames of the runtime, of the standard library, of gorilla/mux and of httptools are dropped, so
+// that the logged stack starts at the application's code.
+var LogFilters = []stack.Filter{
+	stack.DropRuntime,
+	stack.DropStdlib,
+	stack.DropModules("github.com/gorilla/mux", "github.com/morelj/httptools"),
+}
+
 // Log is the default LoggerFunc.
-// It logs the value of r and the raw stack.
+// It logs the value of r and the stack, filtered using LogFilters and with shortened paths.
 func Log(r *http.Request, err Error, stack stack.Stack) {
 	log.Errorf("Error (%d): %s\n", err.StatusCode(), err.Error())
-	log.Errorf(string(stack.Raw))
+	log.Errorf("%s", stack.TrimPanic().Filter(LogFilters...).ShortenPaths().Text())
 }
 
 // NoOpLog is a no-operation LoggerFunc.
@@ -131,6 +149,7 @@ func NoOpLog(*http.Request, Error, stack.Stack) {}
 func WriteTextErrorResponse(err Error, w http.ResponseWriter) error {
 	return response.NewBuilder().
 		WithStatus(err.StatusCode()).
+		WithHeaders(Header(err)).
 		WithHeader(header.ContentType, "text/plain").
 		WithBody(err.Error()).
 		Write(w)
@@ -142,6 +161,7 @@ func NewJSONErrorResponseWriter(newValue func(err Error) interface{}) ErrorRespo
 	return ErrorResponseWriterFunc(func(err Error, w http.ResponseWriter) error {
 		return response.NewBuilder().
 			WithStatus(err.StatusCode()).
+			WithHeaders(Header(err)).
 			WithJSONBody(newValue(err)).
 			Write(w)
 	})
@@ -0,0 +1,87 @@
+package httperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/morelj/httptools/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapContext(t *testing.T) {
+	notFound := New(http.StatusNotFound, "Not found")
+
+	cases := []struct {
+		r       any
+		status  int
+		message string
+		is      error
+	}{
+		{
+			r:       notFound,
+			status:  http.StatusNotFound,
+			message: "Not found",
+		},
+		{
+			r:       fmt.Errorf("loading user: %w", notFound),
+			status:  http.StatusInternalServerError,
+			message: "loading user: Not found",
+			is:      notFound,
+		},
+		{
+			r:       customErr("error"),
+			status:  http.StatusInternalServerError,
+			message: "error",
+			is:      customErr("error"),
+		},
+		{
+			r:       fmt.Errorf("loading user: %w", io.ErrUnexpectedEOF),
+			status:  http.StatusInternalServerError,
+			message: "loading user: unexpected EOF",
+			is:      io.ErrUnexpectedEOF,
+		},
+		{
+			r:       "boom",
+			status:  http.StatusInternalServerError,
+			message: "Panic: boom",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := WrapContext(context.Background(), c.r, stack.Stack{})
+			assert.Equal(c.status, err.StatusCode())
+			assert.Equal(c.message, err.Error())
+			if c.is != nil {
+				assert.True(errors.Is(err, c.is))
+			}
+		})
+	}
+}
+
+func TestMiddlewareWritesToOriginalWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	w := httptest.NewRecorder()
+	var written http.ResponseWriter
+	ew := func(err Error, w http.ResponseWriter) error {
+		written = w
+		return WriteTextErrorResponse(err, w)
+	}
+	handler := NewCustomContextMiddleware(ew, WrapContext, NoOpLog)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Same(w, written)
+	_, ok := written.(http.Flusher)
+	assert.True(ok)
+	assert.Equal(http.StatusInternalServerError, w.Code)
+}
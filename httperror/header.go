@@ -0,0 +1,61 @@
+package httperror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// headerError is an Error carrying headers to be written along with the error response
+type headerError struct {
+	err    Error
+	header http.Header
+}
+
+// Error returns the wrapped error's message
+func (e headerError) Error() string {
+	return e.err.Error()
+}
+
+// StatusCode returns the wrapped error's status code
+func (e headerError) StatusCode() int {
+	return e.err.StatusCode()
+}
+
+// Header returns the headers of the error
+func (e headerError) Header() http.Header {
+	return e.header
+}
+
+func (e headerError) Unwrap() error {
+	return e.err
+}
+
+// MarshalJSON serializes the wrapped error, so that headers don't change the JSON representation of errors
+func (e headerError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.err)
+}
+
+// WithHeader returns a copy of err with an additional header which will be written along with the error
+// response by the ErrorResponseWriterFuncs of this package.
+func WithHeader(err Error, key, value string) Error {
+	h := http.Header{}
+	if he, ok := err.(headerError); ok {
+		h = he.header.Clone()
+		err = he.err
+	}
+	h.Add(key, value)
+	return headerError{
+		err:    err,
+		header: h,
+	}
+}
+
+// Header returns the headers attached to err using WithHeader, or nil if there are none.
+func Header(err error) http.Header {
+	var he interface{ Header() http.Header }
+	if errors.As(err, &he) {
+		return he.Header()
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package httperror
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/morelj/httptools/header"
+)
+
+// maxUpstreamBodySize is the maximum number of bytes read from an upstream error response
+const maxUpstreamBodySize = 64 << 10
+
+// Problem holds the standard members of an RFC 9457 (application/problem+json) error response.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// UpstreamError is an Error decoded from the error response of an upstream service.
+// Its status code is the one of the upstream response.
+//
+// UpstreamError is serialized to JSON using the same format as WriteDefaultJSONErrorResponse.
+type UpstreamError struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code,omitempty"`
+
+	// Problem is set if the upstream response was an application/problem+json document
+	Problem *Problem `json:"-"`
+
+	// Header holds the headers of the upstream response
+	Header http.Header `json:"-"`
+}
+
+// Error returns the error's message
+func (e *UpstreamError) Error() string {
+	return e.Message
+}
+
+// StatusCode returns the status code of the upstream response
+func (e *UpstreamError) StatusCode() int {
+	return e.Code
+}
+
+// FromResponse reads the body of resp, and returns the Error it represents.
+//
+// The body can be either a JSON error as written by WriteDefaultJSONErrorResponse, an application/problem+json
+// document, or plain text (other formats are ignored). The status code of the returned error is always the one of resp.
+// The body of resp is closed.
+func FromResponse(resp *http.Response) *UpstreamError {
+	defer resp.Body.Close()
+
+	err := &UpstreamError{
+		Code:   resp.StatusCode,
+		Header: resp.Header,
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBodySize))
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(header.ContentType))
+	switch {
+	case mediaType == "application/problem+json":
+		var problem Problem
+		if json.Unmarshal(data, &problem) == nil {
+			err.Problem = &problem
+			err.Message = problem.Detail
+			if err.Message == "" {
+				err.Message = problem.Title
+			}
+		}
+
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var v struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &v) == nil {
+			err.Message = v.Message
+		}
+
+	case mediaType == "" || mediaType == "text/plain":
+		err.Message = strings.TrimSpace(string(data))
+	}
+
+	if err.Message == "" {
+		err.Message = http.StatusText(resp.StatusCode)
+	}
+	return err
+}
+
+// A StatusTranslator translates the status code of an upstream error into the status code returned to clients.
+type StatusTranslator func(upstream int) int
+
+// TranslateGateway is the default StatusTranslator.
+// Status codes below 500, 503 Service Unavailable and 504 Gateway Timeout are kept as is, other 5xx status codes
+// are translated into 502 Bad Gateway.
+func TranslateGateway(upstream int) int {
+	switch {
+	case upstream < 500, upstream == http.StatusServiceUnavailable, upstream == http.StatusGatewayTimeout:
+		return upstream
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// Propagate returns an Error to be returned to clients, from an error which occurred while calling an upstream
+// service.
+//
+// If err is, or wraps, an Error, its message is kept and its status code translated using translate, or
+// TranslateGateway if translate is nil. The Retry-After header of upstream 429 and 503 responses is kept.
+// Any other error results in a 502 Bad Gateway Error.
+// The returned Error wraps err.
+func Propagate(err error, translate StatusTranslator) Error {
+	if translate == nil {
+		translate = TranslateGateway
+	}
+
+	var upstream Error
+	if !errors.As(err, &upstream) {
+		return NewWithError(err, http.StatusBadGateway, http.StatusText(http.StatusBadGateway))
+	}
+
+	res := NewWithError(err, translate(upstream.StatusCode()), upstream.Error())
+
+	h := Header(err)
+	if ue, ok := upstream.(*UpstreamError); ok {
+		h = ue.Header
+	}
+	if retryAfter := h.Get(header.RetryAfter); retryAfter != "" {
+		switch res.StatusCode() {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			res = WithHeader(res, header.RetryAfter, retryAfter)
+		}
+	}
+	return res
+}
@@ -0,0 +1,129 @@
+package httperror
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/header"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromResponse(t *testing.T) {
+	cases := []struct {
+		status      int
+		contentType string
+		body        string
+		message     string
+		problem     *Problem
+	}{
+		{
+			status:      http.StatusNotFound,
+			contentType: "application/json",
+			body:        `{"message":"user not found","code":404}`,
+			message:     "user not found",
+		},
+		{
+			status:      http.StatusConflict,
+			contentType: "application/problem+json",
+			body:        `{"type":"https://example.com/conflict","title":"Conflict","status":409,"detail":"already exists"}`,
+			message:     "already exists",
+			problem: &Problem{
+				Type:   "https://example.com/conflict",
+				Title:  "Conflict",
+				Status: http.StatusConflict,
+				Detail: "already exists",
+			},
+		},
+		{
+			status:      http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+			body:        "invalid id\n",
+			message:     "invalid id",
+		},
+		{
+			status:      http.StatusInternalServerError,
+			contentType: "text/html",
+			body:        "<html></html>",
+			message:     "Internal Server Error",
+		},
+		{
+			status:      http.StatusBadGateway,
+			contentType: "application/json",
+			body:        `not json`,
+			message:     "Bad Gateway",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := FromResponse(&http.Response{
+				StatusCode: c.status,
+				Header:     http.Header{header.ContentType: {c.contentType}},
+				Body:       io.NopCloser(strings.NewReader(c.body)),
+			})
+			assert.Equal(c.status, err.StatusCode())
+			assert.Equal(c.message, err.Error())
+			assert.Equal(c.problem, err.Problem)
+		})
+	}
+}
+
+func TestPropagate(t *testing.T) {
+	cases := []struct {
+		err        error
+		translate  StatusTranslator
+		status     int
+		message    string
+		retryAfter string
+	}{
+		{
+			err:     &UpstreamError{Message: "not found", Code: http.StatusNotFound},
+			status:  http.StatusNotFound,
+			message: "not found",
+		},
+		{
+			err:     fmt.Errorf("calling service: %w", &UpstreamError{Message: "panic", Code: http.StatusInternalServerError}),
+			status:  http.StatusBadGateway,
+			message: "panic",
+		},
+		{
+			err: &UpstreamError{
+				Message: "overloaded",
+				Code:    http.StatusServiceUnavailable,
+				Header:  http.Header{header.RetryAfter: {"10"}},
+			},
+			status:     http.StatusServiceUnavailable,
+			message:    "overloaded",
+			retryAfter: "10",
+		},
+		{
+			err:       &UpstreamError{Message: "not found", Code: http.StatusNotFound},
+			translate: func(int) int { return http.StatusInternalServerError },
+			status:    http.StatusInternalServerError,
+			message:   "not found",
+		},
+		{
+			err:     errors.New("connection refused"),
+			status:  http.StatusBadGateway,
+			message: "Bad Gateway",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := Propagate(c.err, c.translate)
+			assert.Equal(c.status, err.StatusCode())
+			assert.Equal(c.message, err.Error())
+			assert.Equal(c.retryAfter, Header(err).Get(header.RetryAfter))
+			assert.ErrorIs(err, c.err)
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package httphandler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+	"github.com/morelj/httptools/response"
+	"github.com/morelj/httptools/stack"
+)
+
+// AuthorizeFunc decides whether a request is allowed to access a debug handler.
+// It returns nil to grant access, or an error otherwise. If the error is an httperror.Error, its status code is used,
+// otherwise 403 Forbidden is returned.
+type AuthorizeFunc func(r *http.Request) error
+
+// AllowLoopback is an AuthorizeFunc granting access to requests coming from a loopback address only.
+// Note that behind a reverse proxy, all requests come from the address of the proxy.
+func AllowLoopback(r *http.Request) error {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return httperror.New(http.StatusForbidden, "Forbidden")
+	}
+	return nil
+}
+
+// GoroutinesHandler is an implementation of http.Handler serving the stacks of all the goroutines of the process.
+//
+// The format is selected using the format query parameter:
+//   - json: the parsed stack.Stack
+//   - text: goroutines grouped in buckets of identical stacks
+//   - html: a page listing the buckets, with a form to filter them
+//
+// If format is not set, html is used for requests accepting text/html and json otherwise.
+// The goroutines can be filtered using the state query parameter, matching their state exactly, and the func query
+// parameter, matching a substring of the function of any of their frames.
+type GoroutinesHandler struct {
+	// Authorize is called before serving each request. If it is nil, all requests are denied.
+	Authorize AuthorizeFunc
+}
+
+// dumpGoroutines returns the stacks of all goroutines
+var dumpGoroutines = stack.Dump
+
+func (h GoroutinesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.authorize(r); err != nil {
+		httperror.WriteTextErrorResponse(err, w)
+		return
+	}
+
+	all, err := stack.Parse(dumpGoroutines())
+	if err != nil {
+		httperror.WriteTextErrorResponse(httperror.NewWithError(err, http.StatusInternalServerError, "Cannot parse goroutines"), w)
+		return
+	}
+	s := filterGoroutines(all, r.URL.Query().Get("state"), r.URL.Query().Get("func"))
+
+	b := response.NewBuilder()
+	switch format(r) {
+	case "json":
+		b.WithJSONBody(s)
+	case "text":
+		b.WithHeader(header.ContentType, "text/plain; charset=utf-8").WithBody(formatBuckets(s.Buckets()))
+	case "html":
+		body, err := renderGoroutines(r, s, states(all))
+		if err != nil {
+			httperror.WriteTextErrorResponse(httperror.NewWithError(err, http.StatusInternalServerError, "Cannot render goroutines"), w)
+			return
+		}
+		b.WithHeader(header.ContentType, "text/html; charset=utf-8").WithBody(body)
+	default:
+		httperror.WriteTextErrorResponse(httperror.Newf(http.StatusBadRequest, "Unsupported format %q", r.URL.Query().Get("format")), w)
+		return
+	}
+	b.MustWrite(w)
+}
+
+// authorize calls the Authorize function and converts its error to an httperror.Error
+func (h GoroutinesHandler) authorize(r *http.Request) httperror.Error {
+	if h.Authorize == nil {
+		return httperror.New(http.StatusForbidden, "Forbidden")
+	}
+	err := h.Authorize(r)
+	if err == nil {
+		return nil
+	}
+	var httpErr httperror.Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return httperror.NewWithError(err, http.StatusForbidden, "Forbidden")
+}
+
+// format returns the format requested by r
+func format(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+	if strings.Contains(r.Header.Get(header.Accept), "text/html") {
+		return "html"
+	}
+	return "json"
+}
+
+// filterGoroutines returns the goroutines of s matching state and fn. Empty values match all goroutines.
+func filterGoroutines(s stack.Stack, state, fn string) stack.Stack {
+	res := stack.Stack{}
+	for _, g := range s.Goroutines {
+		if state != "" && g.State != state {
+			continue
+		}
+		if fn != "" && !hasFunc(g, fn) {
+			continue
+		}
+		res.Goroutines = append(res.Goroutines, g)
+	}
+	return res
+}
+
+// hasFunc returns true if the function of any of the frames of g contains fn
+func hasFunc(g stack.Goroutine, fn string) bool {
+	for _, e := range g.Elements {
+		if strings.Contains(e.Func, fn) {
+			return true
+		}
+	}
+	return g.CreatedBy != nil && strings.Contains(g.CreatedBy.Func, fn)
+}
+
+// formatBuckets returns the textual representation of buckets
+func formatBuckets(buckets []stack.Bucket) []byte {
+	var buf bytes.Buffer
+	for i, b := range buckets {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(&buf, "%d goroutine(s) [%s]", b.Count, formatStates(b.States))
+		if b.MaxWait > 0 {
+			fmt.Fprintf(&buf, " [%v - %v]", b.MinWait, b.MaxWait)
+		}
+		ids := make([]string, len(b.IDs))
+		for i, id := range b.IDs {
+			ids[i] = strconv.Itoa(id)
+		}
+		fmt.Fprintf(&buf, ": %s\n", strings.Join(ids, ", "))
+		for _, e := range b.Elements {
+			fmt.Fprintf(&buf, "%s\n\t%s\n", e.Func, e.Source)
+		}
+		if b.Elided {
+			buf.WriteString("...additional frames elided...\n")
+		}
+		if b.CreatedBy != nil {
+			fmt.Fprintf(&buf, "%s\n\t%s\n", b.CreatedBy.Func, b.CreatedBy.Source)
+		}
+	}
+	return buf.Bytes()
+}
+
+// formatStates returns the histogram of states, sorted by state (e.g. "chan receive: 2, select: 1")
+func formatStates(states map[string]int) string {
+	names := make([]string, 0, len(states))
+	for state := range states {
+		names = append(names, state)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, state := range names {
+		parts[i] = fmt.Sprintf("%s: %d", state, states[state])
+	}
+	return strings.Join(parts, ", ")
+}
+
+var goroutinesTemplate = template.Must(template.New("goroutines").Funcs(template.FuncMap{
+	"states": formatStates,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Goroutines</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; }
+.source { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{ .Total }} goroutine(s) in {{ len .Buckets }} bucket(s)</h1>
+<form method="get">
+<input type="hidden" name="format" value="html">
+<label>State <select name="state">
+<option value="">All</option>
+{{- range .States }}
+<option{{ if eq . $.State }} selected{{ end }}>{{ . }}</option>
+{{- end }}
+</select></label>
+<label>Function <input type="text" name="func" value="{{ .Func }}"></label>
+<button type="submit">Filter</button>
+</form>
+{{- range .Buckets }}
+<h2>{{ .Count }} goroutine(s) [{{ states .States }}]{{ if .MaxWait }} [{{ .MinWait }} - {{ .MaxWait }}]{{ end }}</h2>
+<pre>
+{{- range .Elements }}
+{{ .Func }}
+	<span class="source">{{ .Source }}</span>
+{{- end }}
+{{- if .Elided }}
+...additional frames elided...
+{{- end }}
+{{- with .CreatedBy }}
+{{ .Func }}
+	<span class="source">{{ .Source }}</span>
+{{- end }}
+</pre>
+{{- end }}
+</body>
+</html>
+`))
+
+// states returns the sorted states of the goroutines of s
+func states(s stack.Stack) []string {
+	seen := map[string]bool{}
+	var res []string
+	for _, g := range s.Goroutines {
+		if !seen[g.State] {
+			seen[g.State] = true
+			res = append(res, g.State)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// renderGoroutines renders the HTML page listing the goroutines of s.
+// states are the states proposed in the filtering form.
+func renderGoroutines(r *http.Request, s stack.Stack, states []string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := goroutinesTemplate.Execute(&buf, map[string]interface{}{
+		"Total":   len(s.Goroutines),
+		"Buckets": s.Buckets(),
+		"States":  states,
+		"State":   r.URL.Query().Get("state"),
+		"Func":    r.URL.Query().Get("func"),
+	})
+	return buf.Bytes(), err
+}
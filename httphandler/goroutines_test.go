@@ -0,0 +1,169 @@
+package httphandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/httpassert"
+	"github.com/morelj/httptools/httperror"
+	"github.com/stretchr/testify/assert"
+)
+
+var goroutines = []byte(`goroutine 1 [running]:
+main.main()
+	/src/app/main.go:20 +0x1d
+
+goroutine 7 [chan receive, 3 minutes]:
+main.worker(0xc000010000)
+	/src/app/worker.go:12 +0x29
+created by main.main in goroutine 1
+	/src/app/main.go:15 +0x45
+
+goroutine 9 [chan receive]:
+main.worker(0xc000010100)
+	/src/app/worker.go:12 +0x29
+created by main.main in goroutine 1
+	/src/app/main.go:15 +0x45
+
+goroutine 10 [select]:
+main.<other>()
+	/src/app/other.go:5 +0x10
+`)
+
+func allowAll(*http.Request) error {
+	return nil
+}
+
+func TestGoroutinesHandler(t *testing.T) {
+	dumpGoroutines = func() []byte {
+		return goroutines
+	}
+
+	cases := []struct {
+		handler GoroutinesHandler
+		url     string
+		accept  string
+		check   func(r *httpassert.Response)
+	}{
+		{
+			handler: GoroutinesHandler{},
+			url:     "/",
+			check: func(r *httpassert.Response) {
+				r.Error(http.StatusForbidden, "Forbidden")
+			},
+		},
+		{
+			handler: GoroutinesHandler{Authorize: func(*http.Request) error {
+				return httperror.New(http.StatusUnauthorized, "Unauthorized")
+			}},
+			url: "/",
+			check: func(r *httpassert.Response) {
+				r.Error(http.StatusUnauthorized, "Unauthorized")
+			},
+		},
+		{
+			handler: GoroutinesHandler{Authorize: func(*http.Request) error {
+				return errors.New("denied")
+			}},
+			url: "/",
+			check: func(r *httpassert.Response) {
+				r.Error(http.StatusForbidden, "Forbidden")
+			},
+		},
+		{
+			handler: GoroutinesHandler{Authorize: allowAll},
+			url:     "/",
+			check: func(r *httpassert.Response) {
+				r.Status(http.StatusOK).
+					Header("Content-Type", "application/json").
+					JSONPath("goroutines.0.id", 1.0).
+					JSONPath("goroutines.3.id", 10.0).
+					JSONPath("goroutines.1.createdBy.name", "main").
+					JSONPath("goroutines.1.wait", 180e9)
+			},
+		},
+		{
+			handler: GoroutinesHandler{Authorize: allowAll},
+			url:     "/?format=json&state=chan+receive&func=worker",
+			check: func(r *httpassert.Response) {
+				r.Status(http.StatusOK).
+					JSONPath("goroutines.0.id", 7.0).
+					JSONPath("goroutines.1.id", 9.0)
+				assert.NotContains(t, r.Recorder.Body.String(), `"goroutine 10"`)
+			},
+		},
+		{
+			handler: GoroutinesHandler{Authorize: allowAll},
+			url:     "/?format=text&func=main.main",
+			check: func(r *httpassert.Response) {
+				r.Status(http.StatusOK).
+					Header("Content-Type", "text/plain; charset=utf-8").
+					Body(`2 goroutine(s) [chan receive: 2] [0s - 3m0s]: 7, 9
+main.worker(...)
+	/src/app/worker.go:12 +0x29
+created by main.main
+	/src/app/main.go:15 +0x45
+
+1 goroutine(s) [running: 1]: 1
+main.main()
+	/src/app/main.go:20 +0x1d
+`)
+			},
+		},
+		{
+			handler: GoroutinesHandler{Authorize: allowAll},
+			url:     "/?state=select",
+			accept:  "text/html,application/xhtml+xml",
+			check: func(r *httpassert.Response) {
+				r.Status(http.StatusOK).Header("Content-Type", "text/html; charset=utf-8")
+				body := r.Recorder.Body.String()
+				assert.Contains(t, body, "<h1>1 goroutine(s) in 1 bucket(s)</h1>")
+				assert.Contains(t, body, "<option selected>select</option>")
+				assert.Contains(t, body, "<option>chan receive</option>")
+				assert.Contains(t, body, "main.&lt;other&gt;()")
+				assert.NotContains(t, body, "main.worker")
+			},
+		},
+		{
+			handler: GoroutinesHandler{Authorize: allowAll},
+			url:     "/?format=yaml",
+			check: func(r *httpassert.Response) {
+				r.Error(http.StatusBadRequest, `Unsupported format "yaml"`)
+			},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, c.url, nil)
+			if c.accept != "" {
+				r.Header.Set("Accept", c.accept)
+			}
+			c.check(httpassert.Serve(t, c.handler, r))
+		})
+	}
+}
+
+func TestAllowLoopback(t *testing.T) {
+	assert := assert.New(t)
+
+	for addr, allowed := range map[string]bool{
+		"127.0.0.1:1234": true,
+		"[::1]:1234":     true,
+		"::1":            true,
+		"10.0.0.1:1234":  false,
+		"":               false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = addr
+		err := AllowLoopback(r)
+		assert.Equal(allowed, err == nil, addr)
+		if !allowed {
+			assert.True(strings.Contains(err.Error(), "Forbidden"), addr)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package binding maps struct fields to and from string values, using struct tags.
+package binding
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field describes a struct field bound to a name
+type Field struct {
+	Name      string
+	Index     []int
+	OmitEmpty bool
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// Fields returns the bound fields of the struct type t, using the given tag key.
+//
+// Tags have the form `key:"name,omitempty"`. Fields tagged with "-" and unexported fields are ignored.
+// Fields without a tag are bound to their Go name. Embedded structs without a tag are flattened.
+func Fields(t reflect.Type, key string) []Field {
+	var fields []Field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup(key)
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && !hasTag && ft.Kind() == reflect.Struct && !isScalar(ft) {
+			for _, sub := range Fields(ft, key) {
+				sub.Index = append([]int{i}, sub.Index...)
+				fields = append(fields, sub)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, Field{
+			Name:      name,
+			Index:     []int{i},
+			OmitEmpty: opts == "omitempty",
+		})
+	}
+	return fields
+}
+
+// FieldByIndex returns the field of v at index, allocating nil embedded pointers if alloc is true.
+// ok is false if a nil embedded pointer has been found and alloc is false.
+func FieldByIndex(v reflect.Value, index []int, alloc bool) (field reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isScalar returns true if values of type t are formatted as a single value
+func isScalar(t reflect.Type) bool {
+	return t == timeType || t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// Format formats v into string values.
+// Slices and arrays result in one string per element, other values in a single string.
+// A nil pointer results in no value at all.
+func Format(v reflect.Value) ([]string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s, err := Format(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s...)
+		}
+		return values, nil
+	}
+
+	s, err := formatScalar(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func formatScalar(v reflect.Value) (string, error) {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		data, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(data), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return "", nil
+		}
+		return formatScalar(v.Elem())
+	}
+	return "", fmt.Errorf("Unsupported type %s", v.Type())
+}
+
+// Set parses values into v.
+// Slices are filled with all the values, other types are set using the first value.
+func Set(v reflect.Value, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer && !isScalar(v.Type()) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := Set(slice.Index(i), []string{s}); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	return setScalar(v, values[0])
+}
+
+func setScalar(v reflect.Value, s string) error {
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("Unsupported type %s", v.Type())
+		}
+		v.SetBytes([]byte(s))
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setScalar(v.Elem(), s)
+	default:
+		return fmt.Errorf("Unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// EncodeForm converts v into url.Values.
+// v must be a url.Values, a map[string]string, a map[string][]string, or a struct (or pointer to struct) whose
+// fields are bound using the "form" tag.
+func EncodeForm(v any) (url.Values, error) {
+	switch v := v.(type) {
+	case url.Values:
+		return v, nil
+	case map[string][]string:
+		return url.Values(v), nil
+	case map[string]string:
+		values := url.Values{}
+		for k, s := range v {
+			values.Set(k, s)
+		}
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Unsupported type %T", v)
+	}
+
+	values := url.Values{}
+	for _, f := range Fields(rv.Type(), "form") {
+		fv, ok := FieldByIndex(rv, f.Index, false)
+		if !ok || (f.OmitEmpty && fv.IsZero()) {
+			continue
+		}
+		s, err := Format(fv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		values[f.Name] = append(values[f.Name], s...)
+	}
+	return values, nil
+}
+
+// DecodeForm sets the fields of the struct pointed to by v from values, using the "form" tag.
+// v can also be a pointer to a url.Values or a map[string]string.
+func DecodeForm(values url.Values, v any) error {
+	switch v := v.(type) {
+	case *url.Values:
+		*v = values
+		return nil
+	case *map[string]string:
+		*v = map[string]string{}
+		for k := range values {
+			(*v)[k] = values.Get(k)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Unsupported type %T", v)
+	}
+	rv = rv.Elem()
+
+	for _, f := range Fields(rv.Type(), "form") {
+		s, ok := values[f.Name]
+		if !ok {
+			continue
+		}
+		fv, _ := FieldByIndex(rv, f.Index, true)
+		if err := Set(fv, s); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Package leakcheck detects goroutines leaked by tests.
+//
+// Call Check at the start of a test: the goroutines running at that time are recorded, and when the test ends, it
+// fails if new goroutines are still running after a grace period.
+//
+//	func TestHandler(t *testing.T) {
+//		leakcheck.Check(t)
+//		...
+//	}
+//
+// Check must not be used in parallel tests, as the goroutines of the other tests would be reported as leaks.
+package leakcheck
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morelj/httptools/stack"
+)
+
+// Config configures the leak checker.
+type Config struct {
+	// GracePeriod is the maximum time given to goroutines to exit once the test has ended.
+	GracePeriod time.Duration
+
+	// RetryInterval is the time waited between two checks, during the grace period.
+	RetryInterval time.Duration
+
+	// IgnoredFuncs are regular expressions matched against the qualified function names of the frames of the
+	// goroutines, as returned by stack.Element.FuncName, and of the frames which created them.
+	// Goroutines having a matching frame are not reported.
+	IgnoredFuncs []*regexp.Regexp
+}
+
+// DefaultIgnoredFuncs matches the functions of goroutines which are known to be benign: the goroutines of the
+// testing package and the signal handling goroutines of the runtime.
+var DefaultIgnoredFuncs = []*regexp.Regexp{
+	regexp.MustCompile(`^testing\.`),
+	regexp.MustCompile(`^os/signal\.`),
+	regexp.MustCompile(`^runtime\.ensureSigM`),
+}
+
+// DefaultConfig is the configuration used by Check.
+var DefaultConfig = Config{
+	GracePeriod:   time.Second,
+	RetryInterval: 10 * time.Millisecond,
+	IgnoredFuncs:  DefaultIgnoredFuncs,
+}
+
+// Check records the running goroutines and registers a cleanup function failing t if goroutines started since are
+// still running at the end of the test.
+// Calling Check(t) is equivalent to calling CustomCheck(t, DefaultConfig)
+func Check(t testing.TB) {
+	t.Helper()
+	CustomCheck(t, DefaultConfig)
+}
+
+// CustomCheck records the running goroutines and registers a cleanup function failing t if goroutines started since
+// are still running at the end of the test, using the given configuration.
+func CustomCheck(t testing.TB, cfg Config) {
+	t.Helper()
+
+	s, err := stack.CaptureAll()
+	if err != nil {
+		t.Fatalf("Cannot capture goroutines: %v", err)
+		return
+	}
+	before := map[int]bool{}
+	for _, g := range s.Goroutines {
+		before[g.ID] = true
+	}
+
+	t.Cleanup(func() {
+		t.Helper()
+
+		leaks, err := wait(before, cfg)
+		if err != nil {
+			t.Errorf("Cannot capture goroutines: %v", err)
+		} else if len(leaks) > 0 {
+			t.Errorf("%s", report(leaks))
+		}
+	})
+}
+
+// wait returns the leaked goroutines, once they have all exited or the grace period has expired
+func wait(before map[int]bool, cfg Config) ([]stack.Goroutine, error) {
+	deadline := time.Now().Add(cfg.GracePeriod)
+	for {
+		leaks, err := find(before, cfg)
+		if err != nil || len(leaks) == 0 || !time.Now().Before(deadline) {
+			return leaks, err
+		}
+		time.Sleep(cfg.RetryInterval)
+	}
+}
+
+// find returns the running goroutines which are not in before nor ignored
+func find(before map[int]bool, cfg Config) ([]stack.Goroutine, error) {
+	s, err := stack.CaptureAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var leaks []stack.Goroutine
+	for _, g := range s.Goroutines {
+		if !before[g.ID] && !ignored(g, cfg.IgnoredFuncs) {
+			leaks = append(leaks, g)
+		}
+	}
+	return leaks, nil
+}
+
+// ignored returns true if any of the frames of g, or its creator, matches one of the patterns
+func ignored(g stack.Goroutine, patterns []*regexp.Regexp) bool {
+	elements := g.Elements
+	if g.CreatedBy != nil {
+		elements = append(elements[:len(elements):len(elements)], *g.CreatedBy)
+	}
+	for _, e := range elements {
+		for _, re := range patterns {
+			if re.MatchString(e.FuncName()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// report returns the failure message listing the leaked goroutines
+func report(leaks []stack.Goroutine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d goroutine(s) leaked:", len(leaks))
+	for _, g := range leaks {
+		fmt.Fprintf(&b, "\n\n%s [%s]", g.Name, g.State)
+		if len(g.Elements) > 0 {
+			fmt.Fprintf(&b, " in %s", g.Elements[0].FuncName())
+		}
+		if c := g.CreatedBy; c != nil {
+			fmt.Fprintf(&b, "\n\tcreated by %s at %s:%d", c.FuncName(), c.File, c.Line)
+		}
+		b.WriteString("\n")
+		for _, e := range g.Elements {
+			fmt.Fprintf(&b, "\n\t%s\n\t\t%s", e.Func, e.Source)
+		}
+	}
+	return b.String()
+}
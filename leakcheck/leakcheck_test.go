@@ -0,0 +1,107 @@
+package leakcheck
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingT records the failures and the cleanup functions of a test
+type recordingT struct {
+	testing.TB
+	failures []string
+	cleanups []func()
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+// end runs the cleanup functions, as done at the end of a test
+func (t *recordingT) end() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func block(stop chan struct{}) {
+	<-stop
+}
+
+func TestCheck(t *testing.T) {
+	cfg := Config{
+		GracePeriod:   100 * time.Millisecond,
+		RetryInterval: time.Millisecond,
+		IgnoredFuncs:  DefaultIgnoredFuncs,
+	}
+
+	cases := []struct {
+		cfg      Config
+		run      func(stop chan struct{})
+		failures int
+	}{
+		{
+			cfg: cfg,
+			run: func(stop chan struct{}) {},
+		},
+		{
+			// Leaked goroutine
+			cfg: cfg,
+			run: func(stop chan struct{}) {
+				go block(stop)
+			},
+			failures: 1,
+		},
+		{
+			// The goroutine exits during the grace period
+			cfg: cfg,
+			run: func(stop chan struct{}) {
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+				}()
+			},
+		},
+		{
+			// Ignored goroutine
+			cfg: Config{
+				GracePeriod:   cfg.GracePeriod,
+				RetryInterval: cfg.RetryInterval,
+				IgnoredFuncs:  []*regexp.Regexp{regexp.MustCompile(`leakcheck\.block$`)},
+			},
+			run: func(stop chan struct{}) {
+				go block(stop)
+				time.Sleep(10 * time.Millisecond)
+			},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			stop := make(chan struct{})
+			defer close(stop)
+
+			rt := &recordingT{TB: t}
+			CustomCheck(rt, c.cfg)
+			c.run(stop)
+			rt.end()
+
+			if assert.Len(rt.failures, c.failures) && c.failures > 0 {
+				assert.Contains(rt.failures[0], "1 goroutine(s) leaked")
+				assert.Contains(rt.failures[0], "in github.com/morelj/httptools/leakcheck.block")
+				assert.Contains(rt.failures[0], "created by github.com/morelj/httptools/leakcheck.TestCheck.func")
+				assert.Contains(rt.failures[0], "leakcheck_test.go:")
+			}
+		})
+	}
+}
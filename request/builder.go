@@ -2,30 +2,55 @@ package request
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/internal/binding"
+	"github.com/morelj/httptools/transport"
 )
 
 // Builder allows to create HTTP requests with a convenient API.
+//
+// Errors occurring while building the request are kept until the request is retrieved using Build or Do.
 type Builder struct {
-	r *http.Request
+	r     *http.Request
+	err   error
+	retry *transport.RetryConfig
+}
+
+// MultipartFile is a file sent in a multipart body.
+type MultipartFile struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Content     io.Reader
+}
+
+// NewBuilder returns a new Builder initialized with http.NewRequestWithContext, to be used for outgoing requests.
+func NewBuilder(ctx context.Context, method, url string) Builder {
+	r, err := http.NewRequestWithContext(ctx, method, url, nil)
+	return Builder{
+		r:   r,
+		err: err,
+	}
 }
 
 // NewTestBuilder returns a new Builder initialized with httptest.NewRequest
 func NewTestBuilder(method, target string, body interface{}) Builder {
 	var reader io.Reader
 	if body != nil {
-		switch body := body.(type) {
-		case string:
-			reader = bytes.NewReader(([]byte)(body))
-		case []byte:
-			reader = bytes.NewReader(body)
-		case io.Reader:
-			reader = body
-		default:
-			panic(fmt.Errorf("Unsupported body type: %T", body))
+		var err error
+		if reader, err = bodyReader(body); err != nil {
+			panic(err)
 		}
 	}
 	return Builder{
@@ -33,13 +58,280 @@ func NewTestBuilder(method, target string, body interface{}) Builder {
 	}
 }
 
+// bodyReader returns a reader on body, which must be a string, a []byte or an io.Reader
+func bodyReader(body interface{}) (io.Reader, error) {
+	switch body := body.(type) {
+	case string:
+		return bytes.NewReader(([]byte)(body)), nil
+	case []byte:
+		return bytes.NewReader(body), nil
+	case io.Reader:
+		return body, nil
+	default:
+		return nil, fmt.Errorf("Unsupported body type: %T", body)
+	}
+}
+
 // WithHeader sets the header key to value
 func (b Builder) WithHeader(key, value string) Builder {
-	b.r.Header.Set(key, value)
+	if b.err == nil {
+		b.r.Header.Set(key, value)
+	}
+	return b
+}
+
+// WithQuery adds value to the query parameter key
+func (b Builder) WithQuery(key, value string) Builder {
+	if b.err == nil {
+		q := b.r.URL.Query()
+		q.Add(key, value)
+		b.setQuery(q)
+	}
+	return b
+}
+
+// WithQueryValues adds all the values to the query parameters
+func (b Builder) WithQueryValues(values url.Values) Builder {
+	if b.err == nil {
+		q := b.r.URL.Query()
+		for k, v := range values {
+			q[k] = append(q[k], v...)
+		}
+		b.setQuery(q)
+	}
+	return b
+}
+
+func (b Builder) setQuery(q url.Values) {
+	b.r.URL.RawQuery = q.Encode()
+	if b.r.RequestURI != "" {
+		// Test requests are server requests, keep RequestURI consistent
+		b.r.RequestURI = b.r.URL.RequestURI()
+	}
+}
+
+// WithBody sets the body of the request, which must be a string, a []byte or an io.Reader.
+func (b Builder) WithBody(body interface{}) Builder {
+	if b.err != nil {
+		return b
+	}
+	reader, err := bodyReader(body)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	switch reader := reader.(type) {
+	case *bytes.Reader:
+		data := make([]byte, reader.Len())
+		reader.Read(data)
+		b.setBody(data)
+	default:
+		b.r.Body = io.NopCloser(reader)
+		b.r.ContentLength = -1
+		b.r.GetBody = nil
+	}
+	return b
+}
+
+// WithJSONBody sets the body of the request to v serialized into JSON, and sets the Content-Type accordingly.
+func (b Builder) WithJSONBody(v interface{}) Builder {
+	if b.err != nil {
+		return b
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.setBody(data)
+	return b.WithHeader(header.ContentType, "application/json")
+}
+
+// WithFormBody sets the body of the request to v serialized into the application/x-www-form-urlencoded format,
+// and sets the Content-Type accordingly.
+// v must be a url.Values, a map[string]string, a map[string][]string, or a struct using the "form" tag.
+func (b Builder) WithFormBody(v interface{}) Builder {
+	if b.err != nil {
+		return b
+	}
+	values, err := binding.EncodeForm(v)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.setBody([]byte(values.Encode()))
+	return b.WithHeader(header.ContentType, "application/x-www-form-urlencoded")
+}
+
+// WithMultipartBody sets the body of the request to a multipart/form-data body, and sets the Content-Type
+// accordingly.
+// fields can be nil, or any value accepted by WithFormBody.
+func (b Builder) WithMultipartBody(fields interface{}, files ...MultipartFile) Builder {
+	if b.err != nil {
+		return b
+	}
+
+	var values url.Values
+	if fields != nil {
+		if values, b.err = binding.EncodeForm(fields); b.err != nil {
+			return b
+		}
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, vs := range values {
+		for _, v := range vs {
+			if b.err = w.WriteField(k, v); b.err != nil {
+				return b
+			}
+		}
+	}
+	for _, f := range files {
+		h := make(map[string][]string)
+		h[header.ContentDisposition] = []string{
+			fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.FieldName), escapeQuotes(f.FileName)),
+		}
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h[header.ContentType] = []string{contentType}
+
+		pw, err := w.CreatePart(h)
+		if err == nil {
+			_, err = io.Copy(pw, f.Content)
+		}
+		if err != nil {
+			b.err = err
+			return b
+		}
+	}
+	if b.err = w.Close(); b.err != nil {
+		return b
+	}
+
+	b.setBody(buf.Bytes())
+	return b.WithHeader(header.ContentType, w.FormDataContentType())
+}
+
+// setBody sets data as the request's body, which can be read several times using GetBody
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes the backslashes and double quotes of s, for use in a quoted header parameter, as done by
+// mime/multipart
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+func (b Builder) setBody(data []byte) {
+	b.r.ContentLength = int64(len(data))
+	b.r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	b.r.Body, _ = b.r.GetBody()
+}
+
+// WithBasicAuth sets the Authorization header to use HTTP Basic Authentication
+func (b Builder) WithBasicAuth(username, password string) Builder {
+	if b.err == nil {
+		b.r.SetBasicAuth(username, password)
+	}
+	return b
+}
+
+// WithBearerToken sets the Authorization header to use the given bearer token
+func (b Builder) WithBearerToken(token string) Builder {
+	return b.WithHeader(header.Authorization, "Bearer "+token)
+}
+
+// WithCookie adds a cookie to the request
+func (b Builder) WithCookie(cookie *http.Cookie) Builder {
+	if b.err == nil {
+		b.r.AddCookie(cookie)
+	}
 	return b
 }
 
-// Request returns the request
+// WithVars sets the gorilla/mux route variables of the request, as returned by mux.Vars.
+// Variables are added to the ones previously set.
+// It allows testing handlers using mux.Vars without a router.
+func (b Builder) WithVars(vars map[string]string) Builder {
+	if b.err == nil {
+		merged := make(map[string]string, len(vars))
+		for k, v := range mux.Vars(b.r) {
+			merged[k] = v
+		}
+		for k, v := range vars {
+			merged[k] = v
+		}
+		b.r = mux.SetURLVars(b.r, merged)
+	}
+	return b
+}
+
+// WithContextValue sets a value in the request's context, as done by context.WithValue
+func (b Builder) WithContextValue(key, value interface{}) Builder {
+	if b.err == nil {
+		b.r = b.r.WithContext(context.WithValue(b.r.Context(), key, value))
+	}
+	return b
+}
+
+// WithRemoteAddr sets the remote address of the request (e.g. "192.0.2.1:1234").
+// It is only meaningful for server requests, such as the ones created by NewTestBuilder.
+func (b Builder) WithRemoteAddr(addr string) Builder {
+	if b.err == nil {
+		b.r.RemoteAddr = addr
+	}
+	return b
+}
+
+// WithRetry makes Do retry the request in case of failure.
+// Calling WithRetry() is equivalent to calling WithCustomRetry(transport.DefaultRetryConfig)
+func (b Builder) WithRetry() Builder {
+	return b.WithCustomRetry(transport.DefaultRetryConfig)
+}
+
+// WithCustomRetry makes Do retry the request in case of failure, using a transport.Retry configured with cfg.
+func (b Builder) WithCustomRetry(cfg transport.RetryConfig) Builder {
+	b.retry = &cfg
+	return b
+}
+
+// Build returns the request, or the first error which occurred while building it
+func (b Builder) Build() (*http.Request, error) {
+	return b.r, b.err
+}
+
+// Request returns the request.
+// It panics if an error occurred while building the request.
 func (b Builder) Request() *http.Request {
+	if b.err != nil {
+		panic(b.err)
+	}
 	return b.r
 }
+
+// Do sends the request using client, and returns a Response to read it.
+// If client is nil, http.DefaultClient is used.
+//
+// Like http.Client.Do, no error is returned for non-2xx responses. Use Response.Err to get them as an Error.
+func (b Builder) Do(client *http.Client) (Response, error) {
+	if b.err != nil {
+		return Response{}, b.err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if b.retry != nil {
+		c := *client
+		c.Transport = transport.NewCustomRetry(client.Transport, *b.retry)
+		client = &c
+	}
+	resp, err := client.Do(b.r)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{r: resp}, nil
+}
@@ -0,0 +1,165 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilderDo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		cookie, _ := r.Cookie("session")
+		var body map[string]string
+		NewReader(r).MustJSON(&body)
+
+		w.Header().Set(header.ContentType, "application/json")
+		io.WriteString(w, `{"query":"`+r.URL.Query().Get("q")+`","auth":"`+user+":"+pass+`","cookie":"`+cookie.Value+`","body":"`+body["key"]+`"}`)
+	}))
+	defer server.Close()
+
+	resp, err := NewBuilder(context.Background(), http.MethodPost, server.URL+"/path").
+		WithQuery("q", "search").
+		WithBasicAuth("user", "pass").
+		WithCookie(&http.Cookie{Name: "session", Value: "id"}).
+		WithJSONBody(map[string]string{"key": "value"}).
+		Do(server.Client())
+	require.NoError(err)
+	assert.Equal(http.StatusOK, resp.StatusCode())
+
+	var v map[string]string
+	require.NoError(resp.JSON(&v))
+	assert.Equal(map[string]string{
+		"query":  "search",
+		"auth":   "user:pass",
+		"cookie": "id",
+		"body":   "value",
+	}, v)
+}
+
+func TestBuilderBodies(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r, err := NewBuilder(context.Background(), http.MethodPost, "http://localhost/").
+		WithFormBody(map[string]string{"a": "b"}).
+		Build()
+	require.NoError(err)
+	assert.Equal("application/x-www-form-urlencoded", r.Header.Get(header.ContentType))
+	assert.Equal(int64(3), r.ContentLength)
+	assert.Equal("a=b", NewReader(r).MustString())
+
+	// The body can be read again
+	body, err := r.GetBody()
+	require.NoError(err)
+	data, _ := io.ReadAll(body)
+	assert.Equal("a=b", string(data))
+
+	r, err = NewBuilder(context.Background(), http.MethodPost, "http://localhost/").
+		WithMultipartBody(map[string]string{"name": "a"}, MultipartFile{
+			FieldName: "file",
+			FileName:  "file.txt",
+			Content:   strings.NewReader("content"),
+		}).
+		Build()
+	require.NoError(err)
+	m, err := NewReader(r).Multipart()
+	require.NoError(err)
+	require.NoError(m.ReadAll())
+	assert.Equal("a", m.Values.Get("name"))
+	require.Len(m.Files, 1)
+	data, _ = m.Files[0].Bytes()
+	assert.Equal("content", string(data))
+
+	// File names are quoted, not escaped as Go strings
+	r, err = NewBuilder(context.Background(), http.MethodPost, "http://localhost/").
+		WithMultipartBody(nil, MultipartFile{
+			FieldName: "file",
+			FileName:  `résumé "v2"\draft.txt`,
+			Content:   strings.NewReader("content"),
+		}).
+		Build()
+	require.NoError(err)
+	m, err = NewReader(r).Multipart()
+	require.NoError(err)
+	require.NoError(m.ReadAll())
+	require.Len(m.Files, 1)
+	assert.Equal(`résumé "v2"\draft.txt`, m.Files[0].FileName)
+
+	_, err = NewBuilder(context.Background(), http.MethodPost, "http://localhost/").
+		WithJSONBody(make(chan int)).
+		WithHeader("key", "value").
+		Build()
+	assert.Error(err)
+}
+
+func TestResponseErr(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	resp, err := NewBuilder(context.Background(), http.MethodGet, server.URL).Do(nil)
+	require.NoError(err)
+
+	var v interface{}
+	err = resp.JSON(&v)
+	var httpErr httperror.Error
+	require.True(errors.As(err, &httpErr))
+	assert.Equal(http.StatusNotFound, httpErr.StatusCode())
+	assert.Equal("not found", httpErr.Error())
+}
+
+type contextKey string
+
+func TestTestBuilder(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := NewTestBuilder(http.MethodPut, "/users/42?fields=name", nil).
+		WithVars(map[string]string{"id": "42"}).
+		WithVars(map[string]string{"org": "acme"}).
+		WithContextValue(contextKey("user"), "admin").
+		WithQuery("fields", "email").
+		WithJSONBody(map[string]string{"name": "user"}).
+		WithCookie(&http.Cookie{Name: "session", Value: "id"}).
+		WithBasicAuth("user", "pass").
+		WithRemoteAddr("192.0.2.1:1234").
+		Request()
+
+	assert.Equal(map[string]string{"id": "42", "org": "acme"}, mux.Vars(r))
+	assert.Equal("admin", r.Context().Value(contextKey("user")))
+	assert.Equal([]string{"name", "email"}, r.URL.Query()["fields"])
+	assert.Equal("/users/42?fields=name&fields=email", r.RequestURI)
+	assert.Equal("application/json", r.Header.Get(header.ContentType))
+	assert.Equal("192.0.2.1:1234", r.RemoteAddr)
+
+	cookie, err := r.Cookie("session")
+	require.NoError(err)
+	assert.Equal("id", cookie.Value)
+
+	user, pass, ok := r.BasicAuth()
+	assert.True(ok)
+	assert.Equal("user", user)
+	assert.Equal("pass", pass)
+
+	var body map[string]string
+	require.NoError(NewReader(r).JSON(&body))
+	assert.Equal(map[string]string{"name": "user"}, body)
+}
@@ -0,0 +1,158 @@
+package request
+
+import (
+	"encoding/xml"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+	"github.com/morelj/httptools/internal/binding"
+	"gopkg.in/yaml.v3"
+)
+
+// A DecoderFunc decodes the body of a request into v.
+// params are the parameters of the request's Content-Type.
+type DecoderFunc func(r Reader, params map[string]string, v interface{}) error
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]DecoderFunc{
+		"application/json":                  decodeJSON,
+		"application/xml":                   decodeXML,
+		"text/xml":                          decodeXML,
+		"application/yaml":                  decodeYAML,
+		"application/x-yaml":                decodeYAML,
+		"text/yaml":                         decodeYAML,
+		"application/x-www-form-urlencoded": decodeForm,
+		"multipart/form-data":               decodeMultipart,
+	}
+)
+
+// RegisterDecoder registers the decoder to be used by Reader.Decode for the given media type.
+// It replaces any decoder previously registered for the same media type, including the built-in ones.
+func RegisterDecoder(mediaType string, decoder DecoderFunc) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(mediaType)] = decoder
+}
+
+// lookupDecoder returns the decoder for mediaType.
+// Structured syntax suffixes (e.g. application/problem+json) fall back to the decoder of the base format.
+func lookupDecoder(mediaType string) (DecoderFunc, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+
+	if decoder, ok := decoders[mediaType]; ok {
+		return decoder, true
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		decoder, ok := decoders["application/"+mediaType[i+1:]]
+		return decoder, ok
+	}
+	return nil, false
+}
+
+// supportedMediaTypes returns the sorted list of registered media types
+func supportedMediaTypes() []string {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+
+	mediaTypes := make([]string, 0, len(decoders))
+	for mediaType := range decoders {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+// Decode decodes the request's body into v, using the decoder registered for the request's Content-Type.
+//
+// Built-in decoders handle JSON, XML, YAML, application/x-www-form-urlencoded and multipart/form-data.
+// Form values are bound to struct fields using the "form" tag (`form:"name"`). Multipart bodies are read using
+// Reader.Multipart, and so are subject to the limits of DefaultMultipartConfig.
+//
+// If the Content-Type has no registered decoder, a 415 Error is returned, with an Accept header listing the
+// supported media types. Malformed bodies result in a 400 Error.
+func (r Reader) Decode(v interface{}) error {
+	contentType := r.r.Header.Get(header.ContentType)
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil && contentType != "" {
+		return httperror.NewWithErrorf(err, http.StatusBadRequest, "Invalid Content-Type: %s", contentType)
+	}
+
+	decoder, ok := lookupDecoder(mediaType)
+	if !ok {
+		supported := strings.Join(supportedMediaTypes(), ", ")
+		var err httperror.Error
+		if mediaType == "" {
+			err = httperror.Newf(http.StatusUnsupportedMediaType, "Missing Content-Type, supported media types are: %s", supported)
+		} else {
+			err = httperror.Newf(http.StatusUnsupportedMediaType, "Unsupported media type %s, supported media types are: %s", mediaType, supported)
+		}
+		return httperror.WithHeader(err, header.Accept, supported)
+	}
+
+	if err := decoder(r, params, v); err != nil {
+		var httpErr httperror.Error
+		if errors.As(err, &httpErr) {
+			return err
+		}
+		return httperror.NewWithErrorf(err, http.StatusBadRequest, "Invalid request body: %v", err)
+	}
+	return nil
+}
+
+// MustDecode decodes the request's body into v, or panics in case of error
+func (r Reader) MustDecode(v interface{}) {
+	if err := r.Decode(v); err != nil {
+		panic(err)
+	}
+}
+
+func decodeJSON(r Reader, params map[string]string, v interface{}) error {
+	return r.JSON(v)
+}
+
+func decodeXML(r Reader, params map[string]string, v interface{}) error {
+	data, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
+
+func decodeYAML(r Reader, params map[string]string, v interface{}) error {
+	data, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+func decodeForm(r Reader, params map[string]string, v interface{}) error {
+	data, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	return binding.DecodeForm(values, v)
+}
+
+func decodeMultipart(r Reader, params map[string]string, v interface{}) error {
+	m, err := r.Multipart()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Bind(v)
+}
@@ -0,0 +1,164 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+	"github.com/stretchr/testify/assert"
+)
+
+type decodeTarget struct {
+	Name  string   `json:"name" xml:"name" yaml:"name" form:"name"`
+	Count int      `json:"count" xml:"count" yaml:"count" form:"count"`
+	Tags  []string `json:"tags" xml:"tag" yaml:"tags" form:"tag"`
+}
+
+func multipartBody(fields map[string][]string) (string, []byte) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, values := range fields {
+		for _, v := range values {
+			w.WriteField(k, v)
+		}
+	}
+	w.Close()
+	return w.FormDataContentType(), buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	multipartType, multipartData := multipartBody(map[string][]string{
+		"name":  {"a"},
+		"count": {"1"},
+		"tag":   {"x", "y"},
+	})
+	expected := decodeTarget{Name: "a", Count: 1, Tags: []string{"x", "y"}}
+
+	cases := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{contentType: "application/json", body: `{"name":"a","count":1,"tags":["x","y"]}`},
+		{contentType: "application/vnd.api+json; charset=utf-8", body: `{"name":"a","count":1,"tags":["x","y"]}`},
+		{contentType: "application/xml", body: `<item><name>a</name><count>1</count><tag>x</tag><tag>y</tag></item>`},
+		{contentType: "application/yaml", body: "name: a\ncount: 1\ntags: [x, y]\n"},
+		{contentType: "application/x-www-form-urlencoded", body: "name=a&count=1&tag=x&tag=y"},
+		{contentType: multipartType, body: string(multipartData)},
+		{contentType: "application/json", body: `{"name":`, status: http.StatusBadRequest},
+		{contentType: "application/x-www-form-urlencoded", body: "count=abc", status: http.StatusBadRequest},
+		{contentType: "text/plain", body: "a", status: http.StatusUnsupportedMediaType},
+		{contentType: "", body: "a", status: http.StatusUnsupportedMediaType},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+			if c.contentType != "" {
+				r.Header.Set(header.ContentType, c.contentType)
+			}
+
+			var v decodeTarget
+			err := NewReader(r).Decode(&v)
+			if c.status != 0 {
+				var httpErr httperror.Error
+				if assert.True(errors.As(err, &httpErr)) {
+					assert.Equal(c.status, httpErr.StatusCode())
+				}
+				if c.status == http.StatusUnsupportedMediaType {
+					assert.Contains(httperror.Header(err).Get(header.Accept), "application/json")
+				}
+			} else {
+				assert.NoError(err)
+				assert.Equal(expected, v)
+			}
+		})
+	}
+}
+
+func TestDecodeMultipartConfig(t *testing.T) {
+	defaultConfig := DefaultMultipartConfig
+	defer func() {
+		DefaultMultipartConfig = defaultConfig
+	}()
+
+	cases := []struct {
+		cfg    MultipartConfig
+		fields map[string]string
+		files  map[string]string
+		status int
+	}{
+		{
+			cfg:    MultipartConfig{MaxTotalSize: 10},
+			fields: map[string]string{"name": strings.Repeat("a", 11)},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			cfg:    MultipartConfig{MaxFileSize: 10},
+			fields: map[string]string{"name": "a"},
+			files:  map[string]string{"file": strings.Repeat("x", 11)},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			cfg:    MultipartConfig{AllowedContentTypes: []string{"image/png"}},
+			fields: map[string]string{"name": "a"},
+			files:  map[string]string{"file": "plain text"},
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			cfg:    MultipartConfig{MaxTotalSize: 10},
+			fields: map[string]string{"name": "a"},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			DefaultMultipartConfig = c.cfg
+			DefaultMultipartConfig.TempDir = t.TempDir()
+
+			var v decodeTarget
+			err := NewReader(newMultipartRequest(c.fields, c.files)).Decode(&v)
+			if c.status != 0 {
+				var httpErr httperror.Error
+				if assert.True(errors.As(err, &httpErr)) {
+					assert.Equal(c.status, httpErr.StatusCode())
+				}
+			} else {
+				assert.NoError(err)
+				assert.Equal("a", v.Name)
+			}
+		})
+	}
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	assert := assert.New(t)
+
+	RegisterDecoder("text/plain", func(r Reader, params map[string]string, v interface{}) error {
+		s, err := r.String()
+		*v.(*string) = s
+		return err
+	})
+	defer func() {
+		decodersMu.Lock()
+		delete(decoders, "text/plain")
+		decodersMu.Unlock()
+	}()
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	r.Header.Set(header.ContentType, "text/plain")
+	var s string
+	assert.NoError(NewReader(r).Decode(&s))
+	assert.Equal("hello", s)
+}
@@ -0,0 +1,144 @@
+package request
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+)
+
+// DefaultMaxDecompressedSize is the default maximum size of a decompressed request body.
+const DefaultMaxDecompressedSize = 32 << 20
+
+// DecompressBody returns a reader decoding body according to the given Content-Encoding header value.
+// Multiple codings are decoded in the reverse order they have been applied.
+//
+// Reading more than maxSize decompressed bytes fails with a 413 Error, which prevents decompression bombs.
+// An unsupported coding results in a 415 Error.
+func DecompressBody(body io.ReadCloser, contentEncoding string, maxSize int64) (io.ReadCloser, error) {
+	codings := strings.Split(contentEncoding, ",")
+	var reader io.Reader = body
+	decoded := false
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+
+		case "gzip", "x-gzip":
+			gr, err := gzip.NewReader(reader)
+			if err != nil {
+				return nil, httperror.NewWithError(err, http.StatusBadRequest, "Invalid gzip body")
+			}
+			reader = gr
+			decoded = true
+
+		case "deflate":
+			dr, err := newDeflateReader(reader)
+			if err != nil {
+				return nil, httperror.NewWithError(err, http.StatusBadRequest, "Invalid deflate body")
+			}
+			reader = dr
+			decoded = true
+
+		default:
+			return nil, httperror.Newf(http.StatusUnsupportedMediaType, "Unsupported Content-Encoding: %s", coding)
+		}
+	}
+
+	if !decoded {
+		return body, nil
+	}
+	return &limitedBody{
+		r:      reader,
+		closer: body,
+		n:      maxSize,
+	}, nil
+}
+
+// newDeflateReader returns a reader decoding the deflate coding, which is zlib-wrapped (RFC 9110 §8.4.1.2).
+// Raw deflate data, as sent by some non-conforming clients, is detected by its missing zlib header and accepted as
+// well.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// NewDecompressionMiddleware returns a middleware which transparently decompresses request bodies according to
+// their Content-Encoding header.
+// The Content-Encoding and Content-Length headers are removed from decompressed requests.
+//
+// Errors are not written by the middleware, but returned to the handler when reading the body:
+// - Reading more than maxSize decompressed bytes fails with a 413 Error
+// - An unsupported encoding fails with a 415 Error
+func NewDecompressionMiddleware(maxSize int64) mux.MiddlewareFunc {
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if contentEncoding := r.Header.Get(header.ContentEncoding); contentEncoding != "" && r.Body != nil {
+				body, err := DecompressBody(r.Body, contentEncoding, maxSize)
+				if err != nil {
+					body = errorBody{err: err, closer: r.Body}
+				}
+				r.Body = body
+				r.ContentLength = -1
+				r.Header.Del(header.ContentEncoding)
+				r.Header.Del(header.ContentLength)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// limitedBody fails with a 413 Error when more than n bytes are read
+type limitedBody struct {
+	r      io.Reader
+	closer io.Closer
+	n      int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n < 0 {
+		return 0, b.tooLarge()
+	}
+	if int64(len(p)) > b.n+1 {
+		// Read at most one extra byte, to detect the limit has been exceeded
+		p = p[:b.n+1]
+	}
+	n, err := b.r.Read(p)
+	b.n -= int64(n)
+	if b.n < 0 {
+		return n + int(b.n), b.tooLarge()
+	}
+	return n, err
+}
+
+func (b *limitedBody) tooLarge() error {
+	return httperror.New(http.StatusRequestEntityTooLarge, "Decompressed request body too large")
+}
+
+func (b *limitedBody) Close() error {
+	return b.closer.Close()
+}
+
+// errorBody always fails with err
+type errorBody struct {
+	err    error
+	closer io.Closer
+}
+
+func (b errorBody) Read([]byte) (int, error) {
+	return 0, b.err
+}
+
+func (b errorBody) Close() error {
+	return b.closer.Close()
+}
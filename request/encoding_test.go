@@ -0,0 +1,133 @@
+package request
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	io.WriteString(w, s)
+	w.Close()
+	return buf.Bytes()
+}
+
+func zlibCompressed(s string) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	io.WriteString(w, s)
+	w.Close()
+	return buf.Bytes()
+}
+
+func deflated(s string) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	io.WriteString(w, s)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestReaderDecompression(t *testing.T) {
+	cases := []struct {
+		body            []byte
+		contentEncoding string
+		maxSize         int64
+		expected        string
+		status          int
+	}{
+		{
+			body:     []byte(`{"key":"value"}`),
+			maxSize:  DefaultMaxDecompressedSize,
+			expected: `{"key":"value"}`,
+		},
+		{
+			body:            gzipped(`{"key":"value"}`),
+			contentEncoding: "gzip",
+			maxSize:         DefaultMaxDecompressedSize,
+			expected:        `{"key":"value"}`,
+		},
+		{
+			body:            zlibCompressed(`{"key":"value"}`),
+			contentEncoding: "deflate",
+			maxSize:         DefaultMaxDecompressedSize,
+			expected:        `{"key":"value"}`,
+		},
+		{
+			// Raw deflate, as sent by non-conforming clients
+			body:            deflated(`{"key":"value"}`),
+			contentEncoding: "deflate",
+			maxSize:         DefaultMaxDecompressedSize,
+			expected:        `{"key":"value"}`,
+		},
+		{
+			body:            gzipped(`{"key":"value"}`),
+			contentEncoding: "identity, gzip",
+			maxSize:         15,
+			expected:        `{"key":"value"}`,
+		},
+		{
+			body:            gzipped(strings.Repeat("a", 1000)),
+			contentEncoding: "gzip",
+			maxSize:         999,
+			status:          http.StatusRequestEntityTooLarge,
+		},
+		{
+			body:            []byte("data"),
+			contentEncoding: "br",
+			maxSize:         DefaultMaxDecompressedSize,
+			status:          http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(c.body))
+			if c.contentEncoding != "" {
+				r.Header.Set(header.ContentEncoding, c.contentEncoding)
+			}
+
+			data, err := NewReader(r).WithMaxDecompressedSize(c.maxSize).String()
+			if c.status != 0 {
+				var httpErr httperror.Error
+				if assert.True(errors.As(err, &httpErr)) {
+					assert.Equal(c.status, httpErr.StatusCode())
+				}
+			} else {
+				assert.NoError(err)
+				assert.Equal(c.expected, data)
+			}
+		})
+	}
+}
+
+func TestDecompressionMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	var data string
+	handler := NewDecompressionMiddleware(DefaultMaxDecompressedSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(r.Header.Get(header.ContentEncoding))
+		data = NewReader(r).MustString()
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(gzipped("hello")))
+	r.Header.Set(header.ContentEncoding, "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal("hello", data)
+}
@@ -0,0 +1,346 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+	"github.com/morelj/httptools/internal/binding"
+)
+
+// sniffLen is the number of bytes used to detect the content type of files
+const sniffLen = 512
+
+// MultipartConfig configures how multipart bodies are read.
+// Zero sizes mean no limit.
+type MultipartConfig struct {
+	// MaxFileSize is the maximum size of a single file.
+	MaxFileSize int64
+
+	// MaxFieldSize is the maximum size of the value of a single field which is not a file. Field values are always
+	// kept in memory.
+	MaxFieldSize int64
+
+	// MaxTotalSize is the maximum size of all the parts, files and fields.
+	MaxTotalSize int64
+
+	// MaxMemory is the size above which a file is spooled to a temporary file instead of being kept in memory.
+	MaxMemory int64
+
+	// TempDir is the directory where files are spooled. The default temporary directory is used if empty.
+	TempDir string
+
+	// AllowedContentTypes lists the allowed content types of files, as detected by http.DetectContentType.
+	// A trailing "/*" matches a whole top-level type (e.g. "image/*"). All types are allowed if empty.
+	AllowedContentTypes []string
+}
+
+// DefaultMultipartConfig is the configuration used by Reader.Multipart.
+var DefaultMultipartConfig = MultipartConfig{
+	MaxFileSize:  32 << 20,
+	MaxFieldSize: 10 << 20,
+	MaxTotalSize: 64 << 20,
+	MaxMemory:    1 << 20,
+}
+
+// Part is a part of a multipart body, which has been read by a MultipartReader.
+type Part struct {
+	// FormName is the name of the form field
+	FormName string
+
+	// FileName is the name of the file, empty if the part is not a file
+	FileName string
+
+	// Header is the header of the part
+	Header textproto.MIMEHeader
+
+	// ContentType is the content type of the part, as detected by http.DetectContentType
+	ContentType string
+
+	// Size is the size of the part, in bytes
+	Size int64
+
+	data []byte
+	path string
+}
+
+// IsFile returns true if the part is a file
+func (p *Part) IsFile() bool {
+	return p.FileName != ""
+}
+
+// Open returns a reader on the content of the part.
+func (p *Part) Open() (io.ReadCloser, error) {
+	if p.path != "" {
+		return os.Open(p.path)
+	}
+	return io.NopCloser(bytes.NewReader(p.data)), nil
+}
+
+// Bytes returns the content of the part.
+func (p *Part) Bytes() ([]byte, error) {
+	if p.path != "" {
+		return os.ReadFile(p.path)
+	}
+	return p.data, nil
+}
+
+// MultipartReader reads the parts of a multipart body one at a time, enforcing size limits.
+//
+// Files larger than the configured memory limit are spooled to temporary files, which are removed by RemoveAll,
+// or automatically once the request's context is done.
+// The reader must be closed once done, to release the body and remove the temporary files.
+type MultipartReader struct {
+	r     *multipart.Reader
+	body  io.Closer
+	cfg   MultipartConfig
+	total int64
+
+	mu    sync.Mutex
+	paths []string
+
+	// Values are the values of the form fields read so far
+	Values url.Values
+
+	// Files are the file parts read so far
+	Files []*Part
+}
+
+// Multipart returns a MultipartReader reading the request's multipart body.
+// Calling Multipart() is equivalent to calling CustomMultipart(DefaultMultipartConfig)
+func (r Reader) Multipart() (*MultipartReader, error) {
+	return r.CustomMultipart(DefaultMultipartConfig)
+}
+
+// CustomMultipart returns a MultipartReader reading the request's multipart body, using the given configuration.
+// A 415 Error is returned if the request is not a multipart request.
+func (r Reader) CustomMultipart(cfg MultipartConfig) (*MultipartReader, error) {
+	mediaType, params, err := mime.ParseMediaType(r.r.Header.Get(header.ContentType))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, httperror.New(http.StatusUnsupportedMediaType, "Expected a multipart body")
+	}
+	if params["boundary"] == "" {
+		return nil, httperror.NewWithError(http.ErrMissingBoundary, http.StatusBadRequest, "Missing multipart boundary")
+	}
+
+	body, err := r.Body()
+	if err != nil {
+		r.r.Body.Close()
+		return nil, err
+	}
+
+	m := &MultipartReader{
+		r:      multipart.NewReader(body, params["boundary"]),
+		body:   body,
+		cfg:    cfg,
+		Values: url.Values{},
+	}
+	context.AfterFunc(r.r.Context(), func() {
+		m.RemoveAll()
+	})
+	return m, nil
+}
+
+// Next reads the next part entirely, and returns it.
+// io.EOF is returned once all parts have been read.
+//
+// A 413 Error is returned if a size limit is exceeded, and a 415 Error if the content type of a file is not
+// allowed.
+func (m *MultipartReader) Next() (*Part, error) {
+	mp, err := m.r.NextPart()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, asHTTPError(err, "Invalid multipart body")
+	}
+	defer mp.Close()
+
+	part := &Part{
+		FormName: mp.FormName(),
+		FileName: mp.FileName(),
+		Header:   mp.Header,
+	}
+
+	limit := limitOrMax(m.cfg.MaxTotalSize) - m.total
+	tooLarge := func() error {
+		return httperror.Newf(http.StatusRequestEntityTooLarge, "Multipart body exceeds the maximum size of %d bytes", m.cfg.MaxTotalSize)
+	}
+	if part.IsFile() && m.cfg.MaxFileSize > 0 && m.cfg.MaxFileSize < limit {
+		limit = m.cfg.MaxFileSize
+		tooLarge = func() error {
+			return httperror.Newf(http.StatusRequestEntityTooLarge, "File %s exceeds the maximum size of %d bytes", part.FileName, limit)
+		}
+	} else if !part.IsFile() && m.cfg.MaxFieldSize > 0 && m.cfg.MaxFieldSize < limit {
+		limit = m.cfg.MaxFieldSize
+		tooLarge = func() error {
+			return httperror.Newf(http.StatusRequestEntityTooLarge, "Field %s exceeds the maximum size of %d bytes", part.FormName, limit)
+		}
+	}
+
+	src := io.LimitReader(mp, limit+1)
+	memLimit := limit
+	if part.IsFile() {
+		memLimit = min(limitOrMax(m.cfg.MaxMemory), limit)
+	}
+
+	// Read in memory up to the memory limit
+	part.data, err = io.ReadAll(io.LimitReader(src, memLimit+1))
+	if err != nil {
+		return nil, asHTTPError(err, "Invalid multipart body")
+	}
+	part.Size = int64(len(part.data))
+
+	if part.Size > memLimit && part.Size <= limit {
+		// Too large to be kept in memory, spool to disk
+		if err := m.spool(part, src); err != nil {
+			return nil, err
+		}
+	}
+	if part.Size > limit {
+		m.remove(part)
+		return nil, tooLarge()
+	}
+	m.total += part.Size
+
+	if !part.IsFile() {
+		m.Values.Add(part.FormName, string(part.data))
+		return part, nil
+	}
+
+	if err := m.sniff(part); err != nil {
+		m.remove(part)
+		return nil, err
+	}
+	m.Files = append(m.Files, part)
+	return part, nil
+}
+
+// ReadAll reads all the remaining parts
+func (m *MultipartReader) ReadAll() error {
+	for {
+		if _, err := m.Next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Bind reads all the remaining parts, then binds the values of the form fields to the struct pointed to by v,
+// using the "form" tag (`form:"name"`).
+func (m *MultipartReader) Bind(v interface{}) error {
+	if err := m.ReadAll(); err != nil {
+		return err
+	}
+	if err := binding.DecodeForm(m.Values, v); err != nil {
+		return httperror.NewWithErrorf(err, http.StatusBadRequest, "Invalid form: %v", err)
+	}
+	return nil
+}
+
+// Close closes the body, and removes all the temporary files created while reading parts.
+// Parts read so far remain available in memory, but those spooled to disk can no longer be opened.
+func (m *MultipartReader) Close() error {
+	return errors.Join(m.body.Close(), m.RemoveAll())
+}
+
+// RemoveAll removes all the temporary files created while reading parts.
+func (m *MultipartReader) RemoveAll() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, path := range m.paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	m.paths = nil
+	return errors.Join(errs...)
+}
+
+// spool writes the content of part, followed by the remaining of src, into a temporary file
+func (m *MultipartReader) spool(part *Part, src io.Reader) error {
+	f, err := os.CreateTemp(m.cfg.TempDir, "multipart-")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	m.mu.Lock()
+	m.paths = append(m.paths, f.Name())
+	m.mu.Unlock()
+	part.path = f.Name()
+
+	if _, err := f.Write(part.data); err != nil {
+		return err
+	}
+	n, err := io.Copy(f, src)
+	if err != nil {
+		return asHTTPError(err, "Invalid multipart body")
+	}
+	part.Size += n
+	part.data = part.data[:min(len(part.data), sniffLen)]
+	return nil
+}
+
+// sniff detects the content type of a file part, and checks it's allowed
+func (m *MultipartReader) sniff(part *Part) error {
+	part.ContentType = http.DetectContentType(part.data[:min(len(part.data), sniffLen)])
+	if part.path != "" {
+		// Only the beginning of the file was kept for sniffing
+		part.data = nil
+	}
+
+	if len(m.cfg.AllowedContentTypes) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(part.ContentType)
+	for _, allowed := range m.cfg.AllowedContentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return nil
+			}
+		} else if mediaType == allowed {
+			return nil
+		}
+	}
+	return httperror.Newf(http.StatusUnsupportedMediaType, "File %s has an unsupported content type: %s", part.FileName, mediaType)
+}
+
+// remove removes the temporary file of part, if any
+func (m *MultipartReader) remove(part *Part) {
+	if part.path != "" {
+		os.Remove(part.path)
+	}
+}
+
+// asHTTPError returns err if it's an Error, or wraps it into a 400 Error
+func asHTTPError(err error, message string) error {
+	var httpErr httperror.Error
+	if errors.As(err, &httpErr) {
+		return err
+	}
+	return httperror.NewWithError(err, http.StatusBadRequest, message)
+}
+
+// limitOrMax returns limit, or the maximum int64 value if limit is 0
+func limitOrMax(limit int64) int64 {
+	if limit <= 0 {
+		return math.MaxInt64 - 1
+	}
+	return limit
+}
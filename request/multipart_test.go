@@ -0,0 +1,161 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = "\x89PNG\x0D\x0A\x1A\x0A"
+
+func newMultipartRequest(fields map[string]string, files map[string]string) *http.Request {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	for name, content := range files {
+		fw, _ := w.CreateFormFile(name, name+".bin")
+		io.WriteString(fw, content)
+	}
+	w.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/", &buf)
+	r.Header.Set(header.ContentType, w.FormDataContentType())
+	return r
+}
+
+func TestMultipart(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	content := pngHeader + strings.Repeat("x", 100)
+	r := newMultipartRequest(map[string]string{"name": "a", "count": "2"}, map[string]string{"file": content})
+
+	m, err := NewReader(r).CustomMultipart(MultipartConfig{
+		MaxFileSize:         1000,
+		MaxFieldSize:        10,
+		MaxMemory:           10,
+		TempDir:             t.TempDir(),
+		AllowedContentTypes: []string{"image/*"},
+	})
+	require.NoError(err)
+	defer m.Close()
+
+	var v struct {
+		Name  string `form:"name"`
+		Count int    `form:"count"`
+	}
+	require.NoError(m.Bind(&v))
+	assert.Equal("a", v.Name)
+	assert.Equal(2, v.Count)
+
+	require.Len(m.Files, 1)
+	file := m.Files[0]
+	assert.Equal("file", file.FormName)
+	assert.Equal("file.bin", file.FileName)
+	assert.Equal("image/png", file.ContentType)
+	assert.Equal(int64(len(content)), file.Size)
+	require.NotEmpty(file.path)
+
+	data, err := file.Bytes()
+	require.NoError(err)
+	assert.Equal(content, string(data))
+
+	require.NoError(m.RemoveAll())
+	_, err = os.Stat(file.path)
+	assert.True(errors.Is(err, os.ErrNotExist))
+}
+
+func TestMultipartErrors(t *testing.T) {
+	cases := []struct {
+		cfg    MultipartConfig
+		fields map[string]string
+		files  map[string]string
+		status int
+	}{
+		{
+			cfg:    MultipartConfig{MaxFileSize: 10},
+			files:  map[string]string{"file": strings.Repeat("x", 11)},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			cfg:    MultipartConfig{MaxTotalSize: 20, MaxMemory: 5},
+			files:  map[string]string{"file": strings.Repeat("x", 21)},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			cfg:    MultipartConfig{MaxFieldSize: 10, MaxMemory: 5},
+			fields: map[string]string{"name": strings.Repeat("x", 11)},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			cfg:    MultipartConfig{AllowedContentTypes: []string{"image/png"}},
+			files:  map[string]string{"file": "plain text"},
+			status: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			c.cfg.TempDir = t.TempDir()
+			m, err := NewReader(newMultipartRequest(c.fields, c.files)).CustomMultipart(c.cfg)
+			if !assert.NoError(err) {
+				return
+			}
+			defer m.Close()
+			err = m.ReadAll()
+			var httpErr httperror.Error
+			if assert.True(errors.As(err, &httpErr)) {
+				assert.Equal(c.status, httpErr.StatusCode())
+			}
+			entries, _ := os.ReadDir(c.cfg.TempDir)
+			assert.Empty(entries)
+		})
+	}
+}
+
+// closeRecorder records whether it has been closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestMultipartClose(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := newMultipartRequest(map[string]string{"name": "a"}, map[string]string{"file": strings.Repeat("x", 100)})
+	body := &closeRecorder{Reader: r.Body}
+	r.Body = body
+
+	m, err := NewReader(r).CustomMultipart(MultipartConfig{MaxMemory: 10, TempDir: t.TempDir()})
+	require.NoError(err)
+	require.NoError(m.ReadAll())
+	require.Len(m.Files, 1)
+	assert.False(body.closed)
+
+	require.NoError(m.Close())
+	assert.True(body.closed)
+	_, err = os.Stat(m.Files[0].path)
+	assert.True(errors.Is(err, os.ErrNotExist))
+}
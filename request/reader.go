@@ -2,24 +2,54 @@ package request
 
 import (
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
+
+	"github.com/morelj/httptools/header"
 )
 
 // Reader wraps an http.Request and provides helper functions to read from it.
+//
+// Compressed bodies (Content-Encoding: gzip or deflate) are transparently decompressed.
 type Reader struct {
-	r *http.Request
+	r                   *http.Request
+	maxDecompressedSize int64
 }
 
 // NewReader returns a new Reader initialized with the given request
 func NewReader(r *http.Request) Reader {
-	return Reader{r: r}
+	return Reader{
+		r:                   r,
+		maxDecompressedSize: DefaultMaxDecompressedSize,
+	}
+}
+
+// WithMaxDecompressedSize returns a copy of the Reader limiting the size of decompressed bodies to n bytes.
+func (r Reader) WithMaxDecompressedSize(n int64) Reader {
+	r.maxDecompressedSize = n
+	return r
+}
+
+// Body returns the request's body, decompressed according to its Content-Encoding header.
+// See DecompressBody for the possible errors.
+func (r Reader) Body() (io.ReadCloser, error) {
+	contentEncoding := r.r.Header.Get(header.ContentEncoding)
+	if contentEncoding == "" {
+		return r.r.Body, nil
+	}
+	return DecompressBody(r.r.Body, contentEncoding, r.maxDecompressedSize)
 }
 
 // Bytes returns the request's body bytes
 func (r Reader) Bytes() ([]byte, error) {
-	defer r.r.Body.Close()
-	return ioutil.ReadAll(r.r.Body)
+	body, err := r.Body()
+	if err != nil {
+		r.r.Body.Close()
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
 }
 
 // MustBytes returns the request's body bytes, or panics in case of error
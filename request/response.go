@@ -0,0 +1,75 @@
+package request
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/morelj/httptools/httperror"
+)
+
+// Response wraps the http.Response of a request sent with Builder.Do, and provides helper functions to read it.
+type Response struct {
+	r *http.Response
+}
+
+// NewResponse returns a new Response initialized with the given response
+func NewResponse(r *http.Response) Response {
+	return Response{r: r}
+}
+
+// Response returns the underlying http.Response
+func (r Response) Response() *http.Response {
+	return r.r
+}
+
+// StatusCode returns the response's status code
+func (r Response) StatusCode() int {
+	return r.r.StatusCode
+}
+
+// Header returns the response's headers
+func (r Response) Header() http.Header {
+	return r.r.Header
+}
+
+// Close closes the response's body.
+// Reading the body using one of the helper functions closes it automatically.
+func (r Response) Close() error {
+	return r.r.Body.Close()
+}
+
+// Bytes returns the response's body bytes
+func (r Response) Bytes() ([]byte, error) {
+	defer r.r.Body.Close()
+	return io.ReadAll(r.r.Body)
+}
+
+// String returns the response's body as a string
+func (r Response) String() (string, error) {
+	data, err := r.Bytes()
+	return string(data), err
+}
+
+// Err returns nil if the response has a 2xx status code.
+// Otherwise the body is read and the response is converted into an *httperror.UpstreamError.
+// Use httperror.Propagate to return it to clients.
+func (r Response) Err() error {
+	if r.r.StatusCode >= 200 && r.r.StatusCode < 300 {
+		return nil
+	}
+	return httperror.FromResponse(r.r)
+}
+
+// JSON parses the response's body as JSON into v.
+// If the response doesn't have a 2xx status code, the error returned by Err is returned instead.
+func (r Response) JSON(v interface{}) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	data, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
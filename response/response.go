@@ -16,6 +16,7 @@ type Builder struct {
 	statusCode int
 	body       interface{}
 	serializer SerializerFunc
+	stream     *stream
 }
 
 // NewBuilder returns a new, ready to use Builder.
@@ -53,6 +54,7 @@ func (b *Builder) WithHeaders(h http.Header) *Builder {
 func (b *Builder) WithBody(body interface{}) *Builder {
 	b.body = body
 	b.serializer = DefaultSerializer
+	b.stream = nil
 	return b
 }
 
@@ -60,6 +62,7 @@ func (b *Builder) WithBody(body interface{}) *Builder {
 func (b *Builder) WithCustomBody(body interface{}, serializer SerializerFunc) *Builder {
 	b.body = body
 	b.serializer = serializer
+	b.stream = nil
 	return b
 }
 
@@ -71,6 +74,7 @@ func (b *Builder) WithJSONBody(body interface{}) *Builder {
 // WithCustomJSONBody sets the body of the response with a JSON serializer which can optionally be indented.
 func (b *Builder) WithCustomJSONBody(body interface{}, indent bool) *Builder {
 	b.body = body
+	b.stream = nil
 	if indent {
 		b.serializer = jsonMarshalIndent
 	} else {
@@ -79,15 +83,50 @@ func (b *Builder) WithCustomJSONBody(body interface{}, indent bool) *Builder {
 	return b.WithHeader(header.ContentType, "application/json")
 }
 
+// WithXMLBody sets the body of the response with an XML serializer.
+func (b *Builder) WithXMLBody(body interface{}) *Builder {
+	return b.WithCustomBody(body, XMLSerializer).WithHeader(header.ContentType, "application/xml")
+}
+
+// WithYAMLBody sets the body of the response with a YAML serializer.
+func (b *Builder) WithYAMLBody(body interface{}) *Builder {
+	return b.WithCustomBody(body, YAMLSerializer).WithHeader(header.ContentType, "application/yaml")
+}
+
+// WithCSVBody sets the body of the response with a CSV serializer.
+// See CSVSerializer for the supported body types.
+func (b *Builder) WithCSVBody(body interface{}) *Builder {
+	return b.WithCustomBody(body, CSVSerializer).WithHeader(header.ContentType, "text/csv")
+}
+
+// WithFormBody sets the body of the response with an application/x-www-form-urlencoded serializer.
+// See FormSerializer for the supported body types.
+func (b *Builder) WithFormBody(body interface{}) *Builder {
+	return b.WithCustomBody(body, FormSerializer).WithHeader(header.ContentType, "application/x-www-form-urlencoded")
+}
+
 // Write writes the response to w.
 // If set, the body is serialized using the serializer.
+// If the body is a stream, it is written until the stream ends or its context is done.
+// An unsupported stream source is reported before anything is written, so that an error response can still be sent.
 func (b *Builder) Write(w http.ResponseWriter) error {
+	var seq Seq
+	if b.stream != nil {
+		var err error
+		if seq, err = b.stream.seq(); err != nil {
+			return err
+		}
+	}
+
 	h := w.Header()
 	for k, v := range b.headers {
 		h[k] = v
 	}
 	w.WriteHeader(b.statusCode)
 
+	if b.stream != nil {
+		return b.stream.write(w, seq)
+	}
 	if b.body != nil {
 		body, err := b.serializer(b.body)
 		if err != nil {
@@ -1,6 +1,16 @@
 package response
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/morelj/httptools/internal/binding"
+	"gopkg.in/yaml.v3"
+)
 
 // DefaultSerializer serializes a []byte or a string to a []byte.
 func DefaultSerializer(v interface{}) ([]byte, error) {
@@ -15,3 +25,110 @@ func DefaultSerializer(v interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("Unsupported type %T", v)
 	}
 }
+
+// XMLSerializer serializes v to XML using encoding/xml, prefixed with the standard XML header.
+func XMLSerializer(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// YAMLSerializer serializes v to YAML, indented with 2 spaces.
+func YAMLSerializer(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CSVSerializer serializes v to CSV.
+//
+// v must be either a [][]string, which is written as is, or a slice of structs (or pointers to structs).
+// When v is a slice of structs, a header row is written first, and each struct is written as a row.
+// Columns are named after the "csv" tag of the fields (`csv:"name"`), or after their Go name.
+// Fields tagged with "-" are ignored, and multiple values (slices) are joined with a comma.
+func CSVSerializer(v interface{}) ([]byte, error) {
+	records, err := csvRecords(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func csvRecords(v interface{}) ([][]string, error) {
+	if records, ok := v.([][]string); ok {
+		return records, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("Unsupported type %T", v)
+	}
+	elemType := rv.Type().Elem()
+	if elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Unsupported type %T", v)
+	}
+
+	fields := binding.Fields(elemType, "csv")
+	records := make([][]string, 0, rv.Len()+1)
+
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.Name
+	}
+	records = append(records, header)
+
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Pointer {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+
+		record := make([]string, len(fields))
+		for j, f := range fields {
+			fv, ok := binding.FieldByIndex(elem, f.Index, false)
+			if !ok {
+				continue
+			}
+			values, err := binding.Format(fv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			record[j] = strings.Join(values, ",")
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// FormSerializer serializes v to the application/x-www-form-urlencoded format.
+//
+// v must be either a url.Values, a map[string]string, a map[string][]string, or a struct (or a pointer to a
+// struct). Struct fields are named after their "form" tag (`form:"name,omitempty"`), or after their Go name.
+func FormSerializer(v interface{}) ([]byte, error) {
+	values, err := binding.EncodeForm(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
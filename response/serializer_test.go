@@ -0,0 +1,85 @@
+package response
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type serializerItem struct {
+	XMLName xml.Name `csv:"-" form:"-" xml:"item" yaml:"-"`
+	Name    string   `csv:"name" form:"name" xml:"name" yaml:"name"`
+	Count   int      `csv:"count" form:"count,omitempty" xml:"count" yaml:"count"`
+	Tags    []string `csv:"tags" form:"tag" xml:"tag" yaml:"tags"`
+	Ignored string   `csv:"-" form:"-" xml:"-" yaml:"-"`
+}
+
+func TestSerializers(t *testing.T) {
+	cases := []struct {
+		serializer SerializerFunc
+		value      interface{}
+		err        bool
+		expected   string
+	}{
+		{
+			serializer: XMLSerializer,
+			value:      serializerItem{Name: "a", Count: 1, Tags: []string{"x", "y"}},
+			expected:   "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<item><name>a</name><count>1</count><tag>x</tag><tag>y</tag></item>",
+		},
+		{
+			serializer: YAMLSerializer,
+			value:      serializerItem{Name: "a", Count: 1, Tags: []string{"x"}},
+			expected:   "name: a\ncount: 1\ntags:\n  - x\n",
+		},
+		{
+			serializer: CSVSerializer,
+			value:      [][]string{{"a", "b"}, {"1", "2,3"}},
+			expected:   "a,b\n1,\"2,3\"\n",
+		},
+		{
+			serializer: CSVSerializer,
+			value: []*serializerItem{
+				{Name: "a", Count: 1, Tags: []string{"x", "y"}, Ignored: "ignored"},
+				{Name: "b"},
+			},
+			expected: "name,count,tags\na,1,\"x,y\"\nb,0,\n",
+		},
+		{
+			serializer: CSVSerializer,
+			value:      "not a slice",
+			err:        true,
+		},
+		{
+			serializer: FormSerializer,
+			value:      serializerItem{Name: "a b", Tags: []string{"x", "y"}, Ignored: "ignored"},
+			expected:   "name=a+b&tag=x&tag=y",
+		},
+		{
+			serializer: FormSerializer,
+			value:      url.Values{"key": {"value"}},
+			expected:   "key=value",
+		},
+		{
+			serializer: FormSerializer,
+			value:      42,
+			err:        true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			data, err := c.serializer(c.value)
+			if c.err {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(c.expected, string(data))
+			}
+		})
+	}
+}
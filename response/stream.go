@@ -0,0 +1,194 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/morelj/httptools/header"
+)
+
+// A Seq is an iterator yielding the values of a stream.
+// It must stop iterating as soon as yield returns false.
+type Seq func(yield func(v any) bool)
+
+var seqType = reflect.TypeOf(Seq(nil))
+
+// StreamFormat is the format used to write a stream of values.
+type StreamFormat int
+
+const (
+	// NDJSON writes each value as a single line of JSON (application/x-ndjson).
+	NDJSON StreamFormat = iota
+	// JSONArray writes the values as the elements of a single JSON array (application/json).
+	JSONArray
+)
+
+// ContentType returns the media type matching the format.
+func (f StreamFormat) ContentType() string {
+	if f == JSONArray {
+		return "application/json"
+	}
+	return "application/x-ndjson"
+}
+
+// StreamError is the sentinel value written as the last value of a stream which ended on an error.
+//
+// A stream ends on an error either when the source yields a value implementing error, or when a value cannot be
+// serialized. With NDJSON, the sentinel is the last line of the stream; with JSONArray, it is the last element
+// of the array, which is still properly closed:
+//
+//	{"error": "error message"}
+type StreamError struct {
+	Error string `json:"error"`
+}
+
+type stream struct {
+	ctx    context.Context
+	src    any
+	format StreamFormat
+}
+
+// WithStream sets the body of the response to a stream of values which are serialized to JSON one at a time,
+// instead of being marshalled as a whole.
+//
+// src must be either a Seq, any func type whose underlying type is func(yield func(any) bool), such as iter.Seq[any],
+// or a channel, which is read until it is closed. Any other type makes Write fail before the status code is written.
+// The stream stops when ctx is done.
+// If the ResponseWriter implements http.Flusher, it is flushed after each value.
+// See StreamError for how errors are reported once the response has started.
+func (b *Builder) WithStream(ctx context.Context, src any, format StreamFormat) *Builder {
+	b.body = nil
+	b.stream = &stream{
+		ctx:    ctx,
+		src:    src,
+		format: format,
+	}
+	return b.WithHeader(header.ContentType, format.ContentType())
+}
+
+// WithNDJSONStream is equivalent to calling WithStream(ctx, src, NDJSON)
+func (b *Builder) WithNDJSONStream(ctx context.Context, src any) *Builder {
+	return b.WithStream(ctx, src, NDJSON)
+}
+
+// WithJSONArrayStream is equivalent to calling WithStream(ctx, src, JSONArray)
+func (b *Builder) WithJSONArrayStream(ctx context.Context, src any) *Builder {
+	return b.WithStream(ctx, src, JSONArray)
+}
+
+// seq returns the source of the stream as a Seq
+func (s *stream) seq() (Seq, error) {
+	if seq, ok := s.src.(Seq); ok {
+		return seq, nil
+	}
+
+	// Match any func type with the signature of Seq, named or not (e.g. iter.Seq[any])
+	src := reflect.ValueOf(s.src)
+	if src.Kind() == reflect.Func && src.Type().ConvertibleTo(seqType) {
+		return src.Convert(seqType).Interface().(Seq), nil
+	}
+
+	ch := src
+	if ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, fmt.Errorf("Unsupported stream type %T", s.src)
+	}
+	return func(yield func(v any) bool) {
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: ch},
+		}
+		for {
+			chosen, v, ok := reflect.Select(cases)
+			if chosen == 0 || !ok {
+				return
+			}
+			if !yield(v.Interface()) {
+				return
+			}
+		}
+	}, nil
+}
+
+// write writes the values of seq to w.
+// The returned error is the error which ended the stream, if any.
+func (s *stream) write(w io.Writer, seq Seq) error {
+	flusher, _ := w.(http.Flusher)
+	count := 0
+
+	// encode serializes v, prefixed or suffixed with the separator required by the format
+	encode := func(v any) ([]byte, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case s.format == NDJSON:
+			data = append(data, '\n')
+		case count > 0:
+			data = append([]byte{','}, data...)
+		}
+		count++
+		return data, nil
+	}
+
+	if s.format == JSONArray {
+		if _, err := w.Write([]byte{'['}); err != nil {
+			return err
+		}
+	}
+
+	var streamErr, writeErr error
+	seq(func(v any) bool {
+		if streamErr = s.ctx.Err(); streamErr != nil {
+			return false
+		}
+		if err, ok := v.(error); ok {
+			streamErr = err
+			return false
+		}
+		data, err := encode(v)
+		if err != nil {
+			streamErr = err
+			return false
+		}
+		if _, writeErr = w.Write(data); writeErr != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	})
+	if writeErr != nil {
+		// The connection is broken, nothing else can be written
+		return writeErr
+	}
+	if err := s.ctx.Err(); err != nil {
+		// The request has been cancelled, the client won't read anything else
+		return err
+	}
+
+	if streamErr != nil {
+		data, err := encode(StreamError{Error: streamErr.Error()})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	if s.format == JSONArray {
+		if _, err := w.Write([]byte{']'}); err != nil {
+			return err
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	return streamErr
+}
@@ -0,0 +1,139 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// namedSeq is a named func type with the signature of Seq, like iter.Seq[any]
+type namedSeq func(yield func(v any) bool)
+
+func TestWithStream(t *testing.T) {
+	values := func(values ...any) Seq {
+		return func(yield func(v any) bool) {
+			for _, v := range values {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+	channel := func(values ...any) <-chan int {
+		ch := make(chan int, len(values))
+		for _, v := range values {
+			ch <- v.(int)
+		}
+		close(ch)
+		return ch
+	}
+
+	cases := []struct {
+		src         any
+		format      StreamFormat
+		err         bool
+		contentType string
+		body        string
+	}{
+		{
+			src:         values(1, "two", map[string]int{"three": 3}),
+			format:      NDJSON,
+			contentType: "application/x-ndjson",
+			body:        "1\n\"two\"\n{\"three\":3}\n",
+		},
+		{
+			src:         values(1, "two", map[string]int{"three": 3}),
+			format:      JSONArray,
+			contentType: "application/json",
+			body:        `[1,"two",{"three":3}]`,
+		},
+		{
+			src:         values(),
+			format:      JSONArray,
+			contentType: "application/json",
+			body:        `[]`,
+		},
+		{
+			src:         namedSeq(values(1, 2)),
+			format:      NDJSON,
+			contentType: "application/x-ndjson",
+			body:        "1\n2\n",
+		},
+		{
+			src:         channel(1, 2, 3),
+			format:      NDJSON,
+			contentType: "application/x-ndjson",
+			body:        "1\n2\n3\n",
+		},
+		{
+			src:         values(1, errors.New("failure"), 3),
+			format:      NDJSON,
+			err:         true,
+			contentType: "application/x-ndjson",
+			body:        "1\n{\"error\":\"failure\"}\n",
+		},
+		{
+			src:         values(1, make(chan int)),
+			format:      JSONArray,
+			err:         true,
+			contentType: "application/json",
+			body:        `[1,{"error":"json: unsupported type: chan int"}]`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			w := httptest.NewRecorder()
+			err := NewBuilder().WithStream(context.Background(), c.src, c.format).Write(w)
+			if c.err {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+			assert.Equal(c.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(c.body, w.Body.String())
+			assert.True(w.Flushed)
+		})
+	}
+}
+
+func TestWithStreamCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	seq := func(yield func(v any) bool) {
+		for i := 1; yield(i); i++ {
+			if i == 2 {
+				cancel()
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	err := NewBuilder().WithNDJSONStream(ctx, seq).Write(w)
+	assert.ErrorIs(err, context.Canceled)
+	assert.Equal("1\n2\n", w.Body.String())
+}
+
+func TestWithStreamUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, src := range []any{[]int{1, 2}, make(chan<- int), func(yield func(v int) bool) {}, nil} {
+		w := httptest.NewRecorder()
+		err := NewBuilder().WithStatus(http.StatusCreated).WithNDJSONStream(context.Background(), src).Write(w)
+		assert.Error(err)
+		// Nothing has been written, an error response can still be sent
+		assert.False(w.Flushed)
+		assert.Empty(w.Header())
+		assert.Zero(w.Body.Len())
+		w.WriteHeader(http.StatusInternalServerError)
+		assert.Equal(http.StatusInternalServerError, w.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package stack
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bucket is a group of goroutines sharing the same signature: the same frames, the same elided flag and the same
+// creator. The values of the arguments and the states of the goroutines are not part of the signature.
+type Bucket struct {
+	// Elements are the frames shared by the goroutines. Args are not set and Func has its arguments replaced by
+	// "(...)".
+	Elements []Element `json:"elements,omitempty"`
+	// Elided is true if the runtime elided some frames of the goroutines
+	Elided bool `json:"elided,omitempty"`
+	// CreatedBy is the frame of the go statement which created the goroutines, if any.
+	// Func does not include the ID of the creator goroutine.
+	CreatedBy *Element `json:"createdBy,omitempty"`
+
+	// Count is the number of goroutines in the bucket
+	Count int `json:"count"`
+	// IDs are the IDs of the goroutines, in the order they appear in the stack
+	IDs []int `json:"ids,omitempty"`
+	// States is the number of goroutines by state
+	States map[string]int `json:"states,omitempty"`
+	// MinWait is the minimum time the goroutines have been blocked
+	MinWait time.Duration `json:"minWait,omitempty"`
+	// MaxWait is the maximum time the goroutines have been blocked
+	MaxWait time.Duration `json:"maxWait,omitempty"`
+}
+
+// Buckets groups the goroutines of the stack by signature.
+// Buckets are sorted by decreasing count, then by order of appearance in the stack.
+func (s Stack) Buckets() []Bucket {
+	var buckets []Bucket
+	index := map[string]int{} // Index of the bucket of each signature
+
+	for _, g := range s.Goroutines {
+		key := g.signature()
+		i, ok := index[key]
+		if !ok {
+			b := Bucket{
+				Elided:  g.Elided,
+				States:  map[string]int{},
+				MinWait: g.Wait,
+				MaxWait: g.Wait,
+			}
+			for _, e := range g.Elements {
+				b.Elements = append(b.Elements, e.withoutArgs())
+			}
+			if g.CreatedBy != nil {
+				// The creator goroutine is not part of the signature
+				createdBy := g.CreatedBy.withoutArgs()
+				createdBy.Func, _, _ = strings.Cut(createdBy.Func, " in goroutine ")
+				b.CreatedBy = &createdBy
+			}
+			buckets = append(buckets, b)
+			i = len(buckets) - 1
+			index[key] = i
+		}
+
+		b := &buckets[i]
+		b.Count++
+		b.IDs = append(b.IDs, g.ID)
+		b.States[g.State]++
+		if g.Wait < b.MinWait {
+			b.MinWait = g.Wait
+		}
+		if g.Wait > b.MaxWait {
+			b.MaxWait = g.Wait
+		}
+	}
+
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return buckets[i].Count > buckets[j].Count
+	})
+	return buckets
+}
+
+// signature returns the key identifying the bucket of g
+func (g Goroutine) signature() string {
+	var b strings.Builder
+	for _, e := range g.Elements {
+		e.writeSignature(&b)
+	}
+	fmt.Fprintf(&b, "elided=%t\n", g.Elided)
+	if g.CreatedBy != nil {
+		b.WriteString("created by ")
+		g.CreatedBy.writeSignature(&b)
+	}
+	return b.String()
+}
+
+// writeSignature writes the signature of the frame to b: its function and source location
+func (e Element) writeSignature(b *strings.Builder) {
+	fmt.Fprintf(b, "%s %s %s %s:%d\n", e.Package, e.Receiver, e.Name, e.File, e.Line)
+}
+
+// withoutArgs returns a copy of e without the argument values
+func (e Element) withoutArgs() Element {
+	if e.Args != nil && strings.HasSuffix(e.Func, ")") {
+		if i := strings.LastIndexByte(e.Func, '('); i > 0 {
+			e.Func = e.Func[:i] + "(...)"
+		}
+	}
+	e.Args = nil
+	return e
+}
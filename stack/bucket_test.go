@@ -0,0 +1,91 @@
+package stack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dump = []byte(`goroutine 1 [running]:
+main.main()
+	/src/app/main.go:20 +0x1d
+
+goroutine 7 [chan receive, 3 minutes]:
+main.worker(0xc000010000, 0x1)
+	/src/app/worker.go:12 +0x29
+created by main.main in goroutine 1
+	/src/app/main.go:15 +0x45
+
+goroutine 8 [select]:
+main.other()
+	/src/app/other.go:5 +0x10
+
+goroutine 9 [chan receive]:
+main.worker(0xc000010100, 0x2)
+	/src/app/worker.go:12 +0x29
+created by main.main in goroutine 1
+	/src/app/main.go:15 +0x45
+
+goroutine 10 [chan send, 12 minutes]:
+main.worker(0xc000010200, 0x3)
+	/src/app/worker.go:12 +0x29
+created by main.main in goroutine 1
+	/src/app/main.go:15 +0x45
+
+goroutine 11 [chan receive]:
+main.worker(0xc000010300, 0x4)
+	/src/app/worker.go:14 +0x31
+created by main.main in goroutine 1
+	/src/app/main.go:15 +0x45
+`)
+
+func TestBuckets(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	stack, err := Parse(dump)
+	require.NoError(err)
+
+	buckets := stack.Buckets()
+	require.Len(buckets, 4)
+
+	assert.Equal(Bucket{
+		Elements: []Element{
+			{
+				Func:    "main.worker(...)",
+				Source:  "/src/app/worker.go:12 +0x29",
+				Package: "main",
+				Name:    "worker",
+				File:    "/src/app/worker.go",
+				Line:    12,
+				Offset:  0x29,
+			},
+		},
+		CreatedBy: &Element{
+			Func:    "created by main.main",
+			Source:  "/src/app/main.go:15 +0x45",
+			Package: "main",
+			Name:    "main",
+			File:    "/src/app/main.go",
+			Line:    15,
+			Offset:  0x45,
+		},
+		Count:   3,
+		IDs:     []int{7, 9, 10},
+		States:  map[string]int{"chan receive": 2, "chan send": 1},
+		MinWait: 0,
+		MaxWait: 12 * time.Minute,
+	}, buckets[0])
+
+	// Buckets of the same size keep the order of the stack
+	assert.Equal([]int{1}, buckets[1].IDs)
+	assert.Equal([]int{8}, buckets[2].IDs)
+	assert.Equal(map[string]int{"select": 1}, buckets[2].States)
+	// Same function, but a different line
+	assert.Equal([]int{11}, buckets[3].IDs)
+
+	// The stack is not modified
+	assert.Equal([]string{"0xc000010000", "0x1"}, stack.Goroutines[1].Elements[0].Args)
+}
@@ -0,0 +1,126 @@
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+)
+
+// maxDepth is the maximum number of frames captured by Capture
+const maxDepth = 128
+
+// Capture returns the stack of the calling goroutine, built from runtime.Callers.
+// It is cheaper and more accurate than parsing the output of debug.Stack, but argument words are not available.
+//
+// skip is the number of frames to skip, 0 identifying the caller of Capture.
+// At most maxDepth frames are captured, Elided being set if the stack is deeper.
+// As the runtime does not print the frames, Func is the qualified name of the function, and Raw is not set: use Text
+// to format the stack the same way as debug.Stack, on demand.
+func Capture(skip int) Stack {
+	// One extra frame is requested to detect elided frames
+	pcs := make([]uintptr, maxDepth+1)
+	n := runtime.Callers(skip+2, pcs)
+
+	g := Goroutine{
+		State:  "running",
+		ID:     currentGoroutineID(),
+		Elided: n > maxDepth,
+	}
+	g.Name = fmt.Sprintf("goroutine %d", g.ID)
+
+	frames := runtime.CallersFrames(pcs[:min(n, maxDepth)])
+
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" || frame.File != "" {
+			g.Elements = append(g.Elements, newElement(frame))
+		}
+		if !more {
+			break
+		}
+	}
+
+	return Stack{
+		Goroutines: []Goroutine{g},
+	}
+}
+
+// Dump returns the stacks of all goroutines, as returned by runtime.Stack with all set to true.
+func Dump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// CaptureAll returns the parsed stacks of all goroutines.
+func CaptureAll() (Stack, error) {
+	return Parse(Dump())
+}
+
+// newElement returns the Element of a runtime.Frame
+func newElement(frame runtime.Frame) Element {
+	e := Element{
+		Func:   frame.Function,
+		Source: fmt.Sprintf("%s:%d", frame.File, frame.Line),
+		File:   frame.File,
+		Line:   frame.Line,
+	}
+	if frame.Entry != 0 && frame.PC >= frame.Entry {
+		e.Offset = uint64(frame.PC - frame.Entry)
+		e.Source += fmt.Sprintf(" +0x%x", e.Offset)
+	}
+	e.Package, e.Receiver, e.Name = splitFuncName(frame.Function)
+	return e
+}
+
+// currentGoroutineID returns the ID of the calling goroutine, read from the header of its stack trace
+func currentGoroutineID() int {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	if groups := goroutineIDRegexp.FindSubmatch(buf); groups != nil {
+		var id int
+		fmt.Sscanf(string(groups[1]), "%d", &id)
+		return id
+	}
+	return 0
+}
+
+// Text returns the textual representation of the stack.
+// It returns Raw if it is set, otherwise the stack is formatted the same way as debug.Stack.
+func (s Stack) Text() []byte {
+	if s.Raw != nil {
+		return s.Raw
+	}
+
+	var buf bytes.Buffer
+	for i, g := range s.Goroutines {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(&buf, "%s [%s]:\n", g.Name, g.State)
+		writeElements(&buf, g)
+		for _, a := range g.Ancestors {
+			fmt.Fprintf(&buf, "[originating from goroutine %d]:\n", a.ID)
+			writeElements(&buf, a)
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeElements writes the elements of g to buf, followed by the elided marker and the creator of g
+func writeElements(buf *bytes.Buffer, g Goroutine) {
+	for _, e := range g.Elements {
+		fmt.Fprintf(buf, "%s\n\t%s\n", e.Func, e.Source)
+	}
+	if g.Elided {
+		buf.WriteString("...additional frames elided...\n")
+	}
+	if g.CreatedBy != nil {
+		fmt.Fprintf(buf, "%s\n\t%s\n", g.CreatedBy.Func, g.CreatedBy.Source)
+	}
+}
@@ -0,0 +1,106 @@
+package stack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type capturer struct{}
+
+func (c *capturer) capture() Stack {
+	return func() Stack {
+		// Skip this closure
+		return Capture(1)
+	}()
+}
+
+func (c *capturer) wait(stop chan struct{}) {
+	<-stop
+}
+
+func TestCapture(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	s := (&capturer{}).capture()
+	require.Len(s.Goroutines, 1)
+	g := s.Goroutines[0]
+	assert.Equal("running", g.State)
+	assert.NotZero(g.ID)
+	require.NotEmpty(g.Elements)
+
+	e := g.Elements[0]
+	assert.Equal("github.com/morelj/httptools/stack", e.Package)
+	assert.Equal("*capturer", e.Receiver)
+	assert.Equal("capture", e.Name)
+	assert.Contains(e.File, "capture_test.go")
+	assert.NotZero(e.Line)
+	assert.Equal("TestCapture", g.Elements[1].Name)
+	assert.Equal("github.com/morelj/httptools/stack.(*capturer).capture", e.Func)
+	assert.False(g.Elided)
+	assert.Nil(s.Raw)
+
+	// The text representation can be parsed back
+	parsed, err := Parse(s.Text())
+	require.NoError(err)
+	require.Len(parsed.Goroutines, 1)
+	assert.Equal(g.ID, parsed.Goroutines[0].ID)
+	require.Len(parsed.Goroutines[0].Elements, len(g.Elements))
+	for i, e := range g.Elements {
+		p := parsed.Goroutines[0].Elements[i]
+		assert.Equal(e.Package, p.Package)
+		assert.Equal(e.Name, p.Name)
+		assert.Equal(e.File, p.File)
+		assert.Equal(e.Line, p.Line)
+		assert.Equal(e.Offset, p.Offset)
+	}
+}
+
+// deep captures the stack once depth nested calls have been made
+func deep(depth int) Stack {
+	if depth == 0 {
+		return Capture(0)
+	}
+	return deep(depth - 1)
+}
+
+func TestCaptureElided(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	s := deep(2 * maxDepth)
+	require.Len(s.Goroutines, 1)
+	assert.True(s.Goroutines[0].Elided)
+	assert.Len(s.Goroutines[0].Elements, maxDepth)
+	assert.Contains(string(s.Text()), "...additional frames elided...")
+}
+
+func TestCaptureAll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go (&capturer{}).wait(stop)
+
+	s, err := CaptureAll()
+	require.NoError(err)
+
+	// The goroutine may not have started yet, find it using its creator
+	var found bool
+	for _, g := range s.Goroutines {
+		if g.CreatedBy != nil && g.CreatedBy.FuncName() == "github.com/morelj/httptools/stack.TestCaptureAll" {
+			found = true
+			assert.Contains(g.CreatedBy.File, "capture_test.go")
+		}
+	}
+	assert.True(found)
+
+	assert.Equal("github.com/morelj/httptools/stack.(*capturer).wait", Element{
+		Package:  "github.com/morelj/httptools/stack",
+		Receiver: "*capturer",
+		Name:     "wait",
+	}.FuncName())
+}
@@ -0,0 +1,174 @@
+package stack
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Panic is a panic value, as printed in the header of a crash log.
+type Panic struct {
+	// Value is the printed panic value. Multi-line values are unindented.
+	Value string `json:"value"`
+	// Recovered is true if the panic was recovered before the process crashed
+	Recovered bool `json:"recovered,omitempty"`
+	// Repanicked is true if the panic was recovered, then raised again with the same value (Go 1.23+)
+	Repanicked bool `json:"repanicked,omitempty"`
+}
+
+// Signal is the signal which caused a crash, e.g. SIGSEGV on a nil pointer dereference.
+type Signal struct {
+	// Name is the name of the signal (e.g. "SIGSEGV"), or its code on some platforms
+	Name string `json:"name"`
+	// Description is the description of the signal (e.g. "segmentation violation")
+	Description string `json:"description,omitempty"`
+	// Code is the signal code
+	Code uint64 `json:"code"`
+	// Addr is the faulting address
+	Addr uint64 `json:"addr"`
+	// PC is the program counter at the time of the signal
+	PC uint64 `json:"pc"`
+}
+
+// Crash is a parsed crash log, as written by the runtime to stderr when a Go process crashes.
+type Crash struct {
+	// Panics is the chain of panics, in the order they have been printed (the first one being the oldest).
+	// It is empty when the process crashed on a fatal error.
+	Panics []Panic `json:"panics,omitempty"`
+	// FatalError is the message of the fatal error which caused the crash (e.g. "concurrent map writes")
+	FatalError string `json:"fatalError,omitempty"`
+	// Signal is the signal which caused the crash, if any
+	Signal *Signal `json:"signal,omitempty"`
+	// Stack is the goroutine dump following the header. The goroutine which crashed comes first.
+	Stack Stack `json:"stack"`
+}
+
+var (
+	dumpStartRegexp  = regexp.MustCompile(`^goroutine \d+ .*\[.+\]:$`)
+	panicRegexp      = regexp.MustCompile(`^\t?panic: (.*)$`)
+	fatalErrorRegexp = regexp.MustCompile(`^fatal error: (.*)$`)
+	signalRegexp     = regexp.MustCompile(`^\[signal (\S+?):?(?: (.+?))? code=(\S+) addr=(\S+) pc=(\S+)\]$`)
+)
+
+const (
+	panicValueGroup        = 1
+	fatalErrorMessageGroup = 1
+	signalNameGroup        = 1
+	signalDescriptionGroup = 2
+	signalCodeGroup        = 3
+	signalAddrGroup        = 4
+	signalPCGroup          = 5
+)
+
+const (
+	recoveredSuffix  = " [recovered]"
+	repanickedSuffix = " [recovered, repanicked]"
+)
+
+// ParseCrash parses a crash log. Any output preceding the panic or fatal error header is ignored, as well as any
+// output following the goroutine dump (e.g. "exit status 2" printed by go run).
+// It returns a Crash with no panic nor fatal error if the log contains no header.
+func ParseCrash(log []byte) (Crash, error) {
+	var res Crash
+
+	var (
+		header bool   // true once the panic or fatal error header has been found
+		value  *Panic // The panic whose value may continue on the next line
+	)
+
+	for rest := log; len(rest) > 0; {
+		l, next, _ := bytes.Cut(rest, []byte("\n"))
+		line := string(bytes.TrimSuffix(l, []byte("\r")))
+		if dumpStartRegexp.MatchString(line) {
+			// Start of the goroutine dump
+			stack, err := Parse(rest[:dumpLength(rest)])
+			res.Stack = stack
+			return res, err
+		}
+		rest = next
+
+		if groups := panicRegexp.FindStringSubmatch(line); groups != nil && (header || !strings.HasPrefix(line, "\t")) {
+			if !strings.HasPrefix(line, "\t") {
+				// Start of a new panic chain, anything before is part of the program output
+				res = Crash{}
+			}
+			header = true
+			res.Panics = append(res.Panics, Panic{Value: groups[panicValueGroup]})
+			value = &res.Panics[len(res.Panics)-1]
+			value.parseSuffix()
+		} else if groups := fatalErrorRegexp.FindStringSubmatch(line); groups != nil {
+			header = true
+			res.FatalError = groups[fatalErrorMessageGroup]
+			value = nil
+		} else if groups := signalRegexp.FindStringSubmatch(line); groups != nil && header {
+			res.Signal = &Signal{
+				Name:        groups[signalNameGroup],
+				Description: groups[signalDescriptionGroup],
+			}
+			res.Signal.Code, _ = strconv.ParseUint(groups[signalCodeGroup], 0, 64)
+			res.Signal.Addr, _ = strconv.ParseUint(groups[signalAddrGroup], 0, 64)
+			res.Signal.PC, _ = strconv.ParseUint(groups[signalPCGroup], 0, 64)
+			value = nil
+		} else if value != nil && strings.HasPrefix(line, "\t") {
+			// Continuation of a multi-line panic value
+			value.Value += "\n" + line[1:]
+			value.Recovered, value.Repanicked = false, false
+			value.parseSuffix()
+		} else {
+			value = nil
+		}
+	}
+
+	return res, nil
+}
+
+// dumpLength returns the length of the goroutine dump at the start of log.
+// The dump ends at the first line which is not part of a stack: a line which is neither indented nor a header,
+// and which is not followed by the indented source line of a frame.
+func dumpLength(log []byte) int {
+	n := 0
+	for rest := log; len(rest) > 0; {
+		l, next, found := bytes.Cut(rest, []byte("\n"))
+		line := string(bytes.TrimSuffix(l, []byte("\r")))
+		if !isDumpLine(line, next) {
+			break
+		}
+		n += len(l)
+		if found {
+			n++
+		}
+		rest = next
+	}
+	return n
+}
+
+// isDumpLine returns true if line is part of a goroutine dump. next is the remaining of the dump.
+func isDumpLine(line string, next []byte) bool {
+	if line == "" || strings.HasPrefix(line, "\t") || goroutineRegexp.MatchString(line) ||
+		ancestorRegexp.MatchString(line) || elidedRegexp.MatchString(line) || createdByRegexp.MatchString(line) {
+		return true
+	}
+	// Function line, followed by its source line
+	return bytes.HasPrefix(next, []byte("\t"))
+}
+
+// parseSuffix removes the recovered suffix of the value, setting Recovered and Repanicked accordingly
+func (p *Panic) parseSuffix() {
+	if v, ok := strings.CutSuffix(p.Value, repanickedSuffix); ok {
+		p.Value = v
+		p.Recovered = true
+		p.Repanicked = true
+	} else if v, ok := strings.CutSuffix(p.Value, recoveredSuffix); ok {
+		p.Value = v
+		p.Recovered = true
+	}
+}
+
+// Goroutine returns the goroutine which crashed, or nil if the crash log has no goroutine dump.
+func (c Crash) Goroutine() *Goroutine {
+	if len(c.Stack.Goroutines) == 0 {
+		return nil
+	}
+	return &c.Stack.Goroutines[0]
+}
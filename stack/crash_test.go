@@ -0,0 +1,126 @@
+package stack
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCrash(t *testing.T) {
+	cases := []struct {
+		file       string
+		prefix     string
+		suffix     string
+		panics     []Panic
+		fatalError string
+		signal     *Signal
+		goroutines []int
+		first      string
+		last       string
+	}{
+		{
+			file: "testdata/crash_signal.txt",
+			panics: []Panic{
+				{Value: "runtime error: invalid memory address or nil pointer dereference"},
+			},
+			signal: &Signal{
+				Name:        "SIGSEGV",
+				Description: "segmentation violation",
+				Code:        0x1,
+				Addr:        0x0,
+				PC:          0x4830e8,
+			},
+			goroutines: []int{1},
+			first:      "nilDeref",
+		},
+		{
+			file:   "testdata/crash_nested.txt",
+			prefix: "panic: this line was logged by the program\nstarting\n",
+			panics: []Panic{
+				{Value: "multi\nline", Recovered: true},
+				{Value: "after recover"},
+			},
+			goroutines: []int{1},
+			first:      "recovered.func1",
+		},
+		{
+			file: "testdata/crash_repanic.txt",
+			panics: []Panic{
+				{Value: "boom", Recovered: true, Repanicked: true},
+			},
+			goroutines: []int{1},
+			first:      "repanic.func1",
+		},
+		{
+			file:   "testdata/crash_repanic.txt",
+			prefix: "starting [server]:\n",
+			panics: []Panic{
+				{Value: "boom", Recovered: true, Repanicked: true},
+			},
+			goroutines: []int{1},
+			first:      "repanic.func1",
+		},
+		{
+			file:   "testdata/crash_repanic.txt",
+			suffix: "exit status 2\n",
+			panics: []Panic{
+				{Value: "boom", Recovered: true, Repanicked: true},
+			},
+			goroutines: []int{1},
+			first:      "repanic.func1",
+			last:       "main",
+		},
+		{
+			file:       "testdata/crash_fatal.txt",
+			fatalError: "all goroutines are asleep - deadlock!",
+			goroutines: []int{1, 6},
+			first:      "deadlock",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			log, err := os.ReadFile(c.file)
+			require.NoError(err)
+
+			crash, err := ParseCrash(append(append([]byte(c.prefix), log...), c.suffix...))
+			require.NoError(err)
+
+			assert.Equal(c.panics, crash.Panics)
+			assert.Equal(c.fatalError, crash.FatalError)
+			assert.Equal(c.signal, crash.Signal)
+
+			var ids []int
+			for _, g := range crash.Stack.Goroutines {
+				ids = append(ids, g.ID)
+			}
+			assert.Equal(c.goroutines, ids)
+
+			g := crash.Goroutine()
+			require.NotNil(g)
+			assert.Equal(c.first, g.Elements[0].Name)
+			if c.last != "" {
+				last := crash.Stack.Goroutines[len(crash.Stack.Goroutines)-1]
+				assert.Equal(c.last, last.Elements[len(last.Elements)-1].Name)
+				assert.NotContains(string(crash.Stack.Raw), c.suffix)
+			}
+			assert.Equal(byte('g'), crash.Stack.Raw[0])
+		})
+	}
+}
+
+func TestParseCrashNoHeader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	crash, err := ParseCrash([]byte("nothing to see here\n"))
+	require.NoError(err)
+	assert.Equal(Crash{}, crash)
+	assert.Nil(crash.Goroutine())
+}
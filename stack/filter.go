@@ -0,0 +1,223 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A Filter decides whether an element of a stack is kept.
+type Filter func(e Element) bool
+
+// Filter returns a copy of the stack keeping only the elements accepted by all the filters.
+// Raw is not set on the returned stack.
+func (s Stack) Filter(filters ...Filter) Stack {
+	return s.mapGoroutines(func(g Goroutine) Goroutine {
+		var elements []Element
+		for _, e := range g.Elements {
+			if keep(e, filters) {
+				elements = append(elements, e)
+			}
+		}
+		g.Elements = elements
+		return g
+	})
+}
+
+// keep returns true if e is accepted by all the filters
+func keep(e Element, filters []Filter) bool {
+	for _, f := range filters {
+		if !f(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// DropRuntime is a Filter dropping the frames of the runtime package and its sub-packages.
+func DropRuntime(e Element) bool {
+	return !isRuntime(e)
+}
+
+// DropStdlib is a Filter dropping the frames of the standard library.
+// A frame is considered to be from the standard library if its file is in GOROOT. When the binary is built with
+// -trimpath, files are not absolute: the frame is then considered to be from the standard library if its package
+// is neither in the main module nor in one of its dependencies, as listed by debug.ReadBuildInfo.
+func DropStdlib(e Element) bool {
+	return !isStdlib(e)
+}
+
+// DropModules returns a Filter dropping the frames of the packages having one of the given path prefixes
+// (e.g. "github.com/gorilla/mux").
+func DropModules(prefixes ...string) Filter {
+	return func(e Element) bool {
+		for _, prefix := range prefixes {
+			if e.Package == prefix || strings.HasPrefix(e.Package, strings.TrimSuffix(prefix, "/")+"/") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// TrimPanic returns a copy of the stack where, in each goroutine which panicked, the frames above the panic site
+// are removed: the frames of the recovering function, runtime.gopanic and the runtime frames which triggered the
+// panic (e.g. runtime.sigpanic). Goroutines which didn't panic are left as is.
+// Raw is not set on the returned stack.
+func (s Stack) TrimPanic() Stack {
+	return s.mapGoroutines(func(g Goroutine) Goroutine {
+		for i, e := range g.Elements {
+			if isPanic(e) {
+				j := i + 1
+				for j < len(g.Elements) && isRuntime(g.Elements[j]) {
+					j++
+				}
+				g.Elements = g.Elements[j:]
+				break
+			}
+		}
+		return g
+	})
+}
+
+// ShortenPaths returns a copy of the stack where file paths are shortened:
+// - files of the standard library are relative to GOROOT/src (e.g. "net/http/server.go")
+// - files of the dependencies in the module cache are relative to it (e.g. "github.com/gorilla/mux@v1.8.0/mux.go")
+// - other files are relative to their package path (e.g. "github.com/user/module/pkg/file.go")
+// The dependencies are the ones listed by debug.ReadBuildInfo.
+// Raw is not set on the returned stack.
+func (s Stack) ShortenPaths() Stack {
+	return s.mapGoroutines(func(g Goroutine) Goroutine {
+		elements := make([]Element, len(g.Elements))
+		for i, e := range g.Elements {
+			elements[i] = e.shortenPath()
+		}
+		g.Elements = elements
+		if g.CreatedBy != nil {
+			createdBy := g.CreatedBy.shortenPath()
+			g.CreatedBy = &createdBy
+		}
+		return g
+	})
+}
+
+// shortenPath returns a copy of e with a shortened file path
+func (e Element) shortenPath() Element {
+	if short := shortPath(e); short != e.File {
+		e.Source = strings.Replace(e.Source, e.File, short, 1)
+		e.File = short
+	}
+	return e
+}
+
+// mapGoroutines returns a copy of the stack where each goroutine has been transformed using fn
+func (s Stack) mapGoroutines(fn func(g Goroutine) Goroutine) Stack {
+	res := Stack{
+		Goroutines: make([]Goroutine, len(s.Goroutines)),
+	}
+	for i, g := range s.Goroutines {
+		res.Goroutines[i] = fn(g)
+	}
+	return res
+}
+
+// isRuntime returns true if e is a frame of the runtime.
+// Since Go 1.17, runtime.gopanic is printed as "panic" without any package.
+func isRuntime(e Element) bool {
+	return e.Package == "runtime" || strings.HasPrefix(e.Package, "runtime/") || isPanic(e)
+}
+
+// isPanic returns true if e is the frame of runtime.gopanic
+func isPanic(e Element) bool {
+	return (e.Package == "runtime" && e.Name == "gopanic") || (e.Package == "" && e.Name == "panic")
+}
+
+// goroot returns the GOROOT directory, from the environment or the runtime
+func goroot() string {
+	if root := os.Getenv("GOROOT"); root != "" {
+		return root
+	}
+	return runtime.GOROOT()
+}
+
+// modules returns the main module and the dependencies of the binary, as listed by debug.ReadBuildInfo
+var modules = sync.OnceValue(func() []*debug.Module {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	return append([]*debug.Module{&info.Main}, info.Deps...)
+})
+
+// inModules returns true if the package pkg belongs to the main module or one of its dependencies
+func inModules(pkg string) bool {
+	for _, m := range modules() {
+		if m.Path != "" && (pkg == m.Path || strings.HasPrefix(pkg, m.Path+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStdlib returns true if e is a frame of the standard library
+func isStdlib(e Element) bool {
+	file := filepath.ToSlash(e.File)
+	if root := goroot(); root != "" && strings.HasPrefix(file, filepath.ToSlash(root)+"/src/") {
+		return true
+	}
+	if e.Package == "" || e.Package == "main" || strings.HasPrefix(file, "/") || filepath.IsAbs(e.File) {
+		return false
+	}
+	// Built with -trimpath, files are relative to GOROOT/src or to their module
+	return !inModules(strings.TrimSuffix(e.Package, "_test"))
+}
+
+// escapeModulePath returns the path of a module as written in the module cache, where upper case letters are
+// escaped as an exclamation mark followed by the lower case letter
+func escapeModulePath(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// shortPath returns the shortened path of the file of e
+func shortPath(e Element) string {
+	file := filepath.ToSlash(e.File)
+	if file == "" {
+		return file
+	}
+
+	if root := goroot(); root != "" {
+		if rel, ok := strings.CutPrefix(file, filepath.ToSlash(root)+"/src/"); ok {
+			return rel
+		}
+	}
+	for _, m := range modules() {
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		if m.Version == "" || m.Version == "(devel)" {
+			// Not in the module cache
+			continue
+		}
+		dir := escapeModulePath(m.Path) + "@" + m.Version + "/"
+		if i := strings.Index(file, "/"+dir); i >= 0 {
+			return file[i+1:]
+		}
+	}
+
+	if e.Package != "" && e.Package != "main" && filepath.IsAbs(e.File) {
+		return strings.TrimSuffix(e.Package, "_test") + "/" + filepath.Base(file)
+	}
+	return file
+}
@@ -0,0 +1,158 @@
+package stack
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var panicStack = []byte(`goroutine 7 [running]:
+github.com/morelj/httptools/httperror.NewCustomContextMiddleware.func1.1.1()
+	/home/user/go/pkg/mod/github.com/morelj/httptools@v1.0.0/httperror/handler.go:66 +0x4c
+panic({0x6e2f00?, 0x9b8a70?})
+	/usr/local/go/src/runtime/panic.go:770 +0x132
+runtime.panicmem(...)
+	/usr/local/go/src/runtime/panic.go:261
+runtime.sigpanic()
+	/usr/local/go/src/runtime/signal_unix.go:881 +0x378
+example.com/app/api.(*Server).getUser(0x0, {0x7a4c10, 0xc0001a8000}, 0xc0001b4000)
+	/src/app/api/users.go:42 +0x1d
+net/http.HandlerFunc.ServeHTTP(0xc000012345?, {0x7a4c10?, 0xc0001a8000?}, 0x0?)
+	/usr/local/go/src/net/http/server.go:2166 +0x29
+github.com/gorilla/mux.(*Router).ServeHTTP(0xc0000c2000, {0x7a4c10, 0xc0001a8000}, 0xc0001b4000)
+	/home/user/go/pkg/mod/github.com/gorilla/mux@v1.8.0/mux.go:210 +0x1c5
+example.com/app/api.logging.func1({0x7a4c10, 0xc0001a8000}, 0xc0001b4000)
+	/src/app/api/middleware.go:12 +0x44
+`)
+
+func TestFilters(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	s, err := Parse(panicStack)
+	require.NoError(err)
+
+	names := func(s Stack) []string {
+		var res []string
+		for _, e := range s.Goroutines[0].Elements {
+			res = append(res, e.Package+"."+e.Name)
+		}
+		return res
+	}
+
+	trimmed := s.TrimPanic()
+	assert.Nil(trimmed.Raw)
+	assert.Equal([]string{
+		"example.com/app/api.getUser",
+		"net/http.HandlerFunc.ServeHTTP",
+		"github.com/gorilla/mux.ServeHTTP",
+		"example.com/app/api.logging.func1",
+	}, names(trimmed))
+
+	assert.Equal([]string{
+		"example.com/app/api.getUser",
+		"example.com/app/api.logging.func1",
+	}, names(trimmed.Filter(DropStdlib, DropModules("github.com/gorilla/mux"))))
+
+	assert.Equal([]string{
+		"github.com/morelj/httptools/httperror.NewCustomContextMiddleware.func1.1.1",
+		"example.com/app/api.getUser",
+		"net/http.HandlerFunc.ServeHTTP",
+		"github.com/gorilla/mux.ServeHTTP",
+		"example.com/app/api.logging.func1",
+	}, names(s.Filter(DropRuntime)))
+
+	// The original stack is not modified
+	assert.Len(s.Goroutines[0].Elements, 8)
+}
+
+func TestShortenPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	s := Stack{
+		Goroutines: []Goroutine{
+			{
+				Elements: []Element{
+					{Package: "example.com/app/api", File: "/src/app/api/users.go", Source: "/src/app/api/users.go:42 +0x1d"},
+					{Package: "main", File: "/src/app/main.go", Source: "/src/app/main.go:10"},
+					{Package: "net/http", File: goroot() + "/src/net/http/server.go", Source: goroot() + "/src/net/http/server.go:2166"},
+				},
+			},
+		},
+	}
+
+	short := s.ShortenPaths()
+	assert.Equal(Element{Package: "example.com/app/api", File: "example.com/app/api/users.go", Source: "example.com/app/api/users.go:42 +0x1d"}, short.Goroutines[0].Elements[0])
+	assert.Equal("/src/app/main.go", short.Goroutines[0].Elements[1].File)
+	assert.Equal("net/http/server.go", short.Goroutines[0].Elements[2].File)
+	assert.Equal("net/http/server.go:2166", short.Goroutines[0].Elements[2].Source)
+}
+
+// withModules replaces the modules of the binary for the duration of the test
+func withModules(t *testing.T, mods ...*debug.Module) {
+	saved := modules
+	modules = func() []*debug.Module {
+		return mods
+	}
+	t.Cleanup(func() {
+		modules = saved
+	})
+}
+
+func TestDropStdlib(t *testing.T) {
+	withModules(t,
+		&debug.Module{Path: "myapp"},
+		&debug.Module{Path: "github.com/gorilla/mux", Version: "v1.8.0"},
+	)
+
+	cases := []struct {
+		e      Element
+		stdlib bool
+	}{
+		{e: Element{Package: "net/http", File: goroot() + "/src/net/http/server.go"}, stdlib: true},
+		{e: Element{Package: "myapp/api", File: "/src/myapp/api/users.go"}},
+		{e: Element{Package: "main", File: "/src/myapp/main.go"}},
+		{e: Element{Package: "example.com/other", File: "/src/other/other.go"}},
+		// Built with -trimpath
+		{e: Element{Package: "net/http", File: "net/http/server.go"}, stdlib: true},
+		{e: Element{Package: "myapp/api", File: "myapp/api/users.go"}},
+		{e: Element{Package: "myapp/api_test", File: "myapp/api/users_test.go"}},
+		{e: Element{Package: "github.com/gorilla/mux", File: "github.com/gorilla/mux@v1.8.0/mux.go"}},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert.Equal(t, !c.stdlib, DropStdlib(c.e))
+		})
+	}
+}
+
+func TestShortenPathsModules(t *testing.T) {
+	assert := assert.New(t)
+
+	withModules(t,
+		&debug.Module{Path: "myapp"},
+		&debug.Module{Path: "github.com/BurntSushi/toml", Version: "v1.3.2"},
+		&debug.Module{Path: "github.com/gorilla/mux", Version: "v1.8.0", Replace: &debug.Module{Path: "github.com/fork/mux", Version: "v1.8.1"}},
+	)
+
+	s := Stack{
+		Goroutines: []Goroutine{
+			{
+				Elements: []Element{
+					{Package: "github.com/BurntSushi/toml", File: "/cache/mod/github.com/!burnt!sushi/toml@v1.3.2/decode.go"},
+					{Package: "github.com/gorilla/mux", File: "/cache/mod/github.com/fork/mux@v1.8.1/mux.go"},
+					{Package: "myapp/api", File: "/home/user/myapp/api/users.go"},
+				},
+			},
+		},
+	}
+
+	short := s.ShortenPaths()
+	assert.Equal("github.com/!burnt!sushi/toml@v1.3.2/decode.go", short.Goroutines[0].Elements[0].File)
+	assert.Equal("github.com/fork/mux@v1.8.1/mux.go", short.Goroutines[0].Elements[1].File)
+	assert.Equal("myapp/api/users.go", short.Goroutines[0].Elements[2].File)
+}
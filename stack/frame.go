@@ -0,0 +1,129 @@
+package stack
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	goroutineIDRegexp = regexp.MustCompile(`^goroutine (\d+)`)
+	waitRegexp        = regexp.MustCompile(`^(\d+) minutes?$`)
+	fileRegexp        = regexp.MustCompile(`^(.+?):(\d+)(?:\s\+0x([0-9a-f]+))?(?:\s.*)?$`)
+)
+
+// parseHeader sets the ID of the goroutine from its name, and its state from the bracketed part of the header
+// line (e.g. "chan receive, 2 minutes, locked to thread").
+func (g *Goroutine) parseHeader(state string) {
+	if groups := goroutineIDRegexp.FindStringSubmatch(g.Name); groups != nil {
+		g.ID, _ = strconv.Atoi(groups[1])
+	}
+
+	parts := strings.Split(state, ", ")
+	g.State = parts[0]
+	for _, part := range parts[1:] {
+		if groups := waitRegexp.FindStringSubmatch(part); groups != nil {
+			minutes, _ := strconv.Atoi(groups[1])
+			g.Wait = time.Duration(minutes) * time.Minute
+		} else if part == "locked to thread" {
+			g.LockedToThread = true
+		} else {
+			// Unknown part, keep it in the state
+			g.State += ", " + part
+		}
+	}
+}
+
+// parseFunc sets the structured fields of the element from its function line,
+// e.g. "github.com/user/pkg.(*T).Method(0x1, {0x2, 0x3})"
+func (e *Element) parseFunc() {
+	name := e.Func
+	if strings.HasPrefix(name, "created by ") {
+		// The creator of the goroutine, e.g. "created by pkg.F in goroutine 1"
+		name = strings.TrimPrefix(name, "created by ")
+		if i := strings.Index(name, " in goroutine "); i >= 0 {
+			name = name[:i]
+		}
+	}
+	if strings.HasSuffix(name, ")") {
+		if i := strings.LastIndexByte(name, '('); i > 0 {
+			e.Args = splitArgs(name[i+1 : len(name)-1])
+			name = name[:i]
+		}
+	}
+	e.Package, e.Receiver, e.Name = splitFuncName(name)
+}
+
+// FuncName returns the qualified name of the function of the element, without its arguments
+// (e.g. "github.com/user/pkg.(*T).Method").
+func (e Element) FuncName() string {
+	name := e.Name
+	if e.Receiver != "" {
+		name = "(" + e.Receiver + ")." + name
+	}
+	if e.Package != "" {
+		name = e.Package + "." + name
+	}
+	return name
+}
+
+// parseSource sets the file, line and offset of the element from its source line,
+// e.g. "/path/to/file.go:42 +0x1d"
+func (e *Element) parseSource() {
+	groups := fileRegexp.FindStringSubmatch(e.Source)
+	if groups == nil {
+		return
+	}
+	e.File = groups[1]
+	e.Line, _ = strconv.Atoi(groups[2])
+	if groups[3] != "" {
+		e.Offset, _ = strconv.ParseUint(groups[3], 16, 64)
+	}
+}
+
+// splitFuncName splits a qualified function name into its package path, pointer receiver type and name.
+// The runtime escapes dots in the last element of package paths as %2e, so the first dot after the last slash
+// always ends the package path.
+func splitFuncName(name string) (pkg, receiver, fn string) {
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot < 0 {
+		return "", "", name
+	}
+	dot += slash + 1
+	pkg = strings.ReplaceAll(name[:dot], "%2e", ".")
+	fn = name[dot+1:]
+
+	if strings.HasPrefix(fn, "(") {
+		if end := strings.Index(fn, ")."); end > 0 {
+			receiver = fn[1:end]
+			fn = fn[end+2:]
+		}
+	}
+	return pkg, receiver, fn
+}
+
+// splitArgs splits the argument words of a call, which are separated by commas outside of braces
+func splitArgs(args string) []string {
+	if args == "" {
+		return nil
+	}
+
+	var res []string
+	depth, start := 0, 0
+	for i, c := range args {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				res = append(res, strings.TrimSpace(args[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(res, strings.TrimSpace(args[start:]))
+}
@@ -0,0 +1,231 @@
+package stack
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SourceLine is a line of a source file.
+type SourceLine struct {
+	// Number is the number of the line in the file, starting at 1
+	Number int `json:"number"`
+	// Text is the content of the line, without the line terminator
+	Text string `json:"text"`
+}
+
+// Snippet is an excerpt of a source file, around the line of a frame.
+type Snippet struct {
+	// Line is the number of the line of the frame
+	Line int `json:"line"`
+	// Lines are the lines of the excerpt, including the line of the frame
+	Lines []SourceLine `json:"lines"`
+}
+
+// SourceConfig configures how a SourceLoader reads source files.
+type SourceConfig struct {
+	// FS is the file system source files are read from. If nil, they are read from disk.
+	// As the paths of frames are absolute, the file is looked up in FS using the longest suffix of its path which
+	// exists in FS, e.g. "/src/app/api/users.go" is looked up as "src/app/api/users.go", then "app/api/users.go",
+	// and finally "api/users.go". The base name alone is never used, as it would match any file having the same
+	// name (e.g. "/usr/local/go/src/net/http/server.go" would match "server.go").
+	FS fs.FS
+
+	// Context is the number of lines included before and after the line of the frame.
+	Context int
+
+	// MaxFileSize is the size above which files are not loaded. Zero means no limit.
+	MaxFileSize int64
+
+	// MaxCacheSize is the maximum total size of the files held in cache. The least recently used files are evicted
+	// first. Zero disables the cache. Files which could not be loaded are not cached.
+	MaxCacheSize int64
+}
+
+// DefaultSourceConfig is the configuration used by NewSourceLoader.
+var DefaultSourceConfig = SourceConfig{
+	Context:      3,
+	MaxFileSize:  1 << 20,
+	MaxCacheSize: 8 << 20,
+}
+
+// ErrSourceTooLarge is returned when a source file is larger than SourceConfig.MaxFileSize.
+var ErrSourceTooLarge = errors.New("source file too large")
+
+// SourceLoader loads snippets of source files, keeping the files in a bounded cache.
+// It is safe for concurrent use.
+type SourceLoader struct {
+	cfg SourceConfig
+
+	lock  sync.Mutex
+	size  int64                    // Total size of the cached files
+	lru   *list.List               // Cached files, the most recently used first
+	files map[string]*list.Element // Cached files by path
+}
+
+// sourceFile is a source file held in cache
+type sourceFile struct {
+	path  string
+	lines []string
+	size  int64
+}
+
+// NewSourceLoader returns a new SourceLoader using DefaultSourceConfig.
+func NewSourceLoader() *SourceLoader {
+	return NewCustomSourceLoader(DefaultSourceConfig)
+}
+
+// NewCustomSourceLoader returns a new SourceLoader using the given configuration.
+func NewCustomSourceLoader(cfg SourceConfig) *SourceLoader {
+	return &SourceLoader{
+		cfg:   cfg,
+		lru:   list.New(),
+		files: map[string]*list.Element{},
+	}
+}
+
+// Snippet returns the snippet of source code around the line of e.
+func (l *SourceLoader) Snippet(e Element) (*Snippet, error) {
+	if e.File == "" || e.Line <= 0 {
+		return nil, fmt.Errorf("no source location for %s", e.Func)
+	}
+
+	f, err := l.file(e.File)
+	if err != nil {
+		return nil, err
+	}
+	if e.Line > len(f.lines) {
+		return nil, fmt.Errorf("%s has no line %d", e.File, e.Line)
+	}
+
+	first := max(e.Line-l.cfg.Context, 1)
+	last := min(e.Line+l.cfg.Context, len(f.lines))
+	s := &Snippet{
+		Line:  e.Line,
+		Lines: make([]SourceLine, 0, last-first+1),
+	}
+	for n := first; n <= last; n++ {
+		s.Lines = append(s.Lines, SourceLine{
+			Number: n,
+			Text:   f.lines[n-1],
+		})
+	}
+	return s, nil
+}
+
+// WithSnippets returns a copy of the stack where the frames have their snippet set, when the source is available.
+// Snippets are loaded for all frames: filter the stack beforehand to only load the frames of the application.
+// As snippets are loaded using the paths of the files, it must be called before ShortenPaths.
+// Raw is not set on the returned stack.
+func (s Stack) WithSnippets(l *SourceLoader) Stack {
+	return s.mapGoroutines(func(g Goroutine) Goroutine {
+		elements := make([]Element, len(g.Elements))
+		for i, e := range g.Elements {
+			if snippet, err := l.Snippet(e); err == nil {
+				e.Snippet = snippet
+			}
+			elements[i] = e
+		}
+		g.Elements = elements
+		return g
+	})
+}
+
+// file returns the source file at path p, from the cache if possible
+func (l *SourceLoader) file(p string) (*sourceFile, error) {
+	if l.cfg.MaxCacheSize <= 0 {
+		return l.load(p)
+	}
+
+	l.lock.Lock()
+	if e, ok := l.files[p]; ok {
+		l.lru.MoveToFront(e)
+		l.lock.Unlock()
+		return e.Value.(*sourceFile), nil
+	}
+	l.lock.Unlock()
+
+	f, err := l.load(p)
+	if err != nil {
+		return nil, err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if e, ok := l.files[p]; ok {
+		// Loaded concurrently
+		l.lru.MoveToFront(e)
+		return e.Value.(*sourceFile), nil
+	}
+	if f.size > l.cfg.MaxCacheSize {
+		return f, nil
+	}
+	l.files[p] = l.lru.PushFront(f)
+	l.size += f.size
+	for l.size > l.cfg.MaxCacheSize {
+		e := l.lru.Back()
+		evicted := l.lru.Remove(e).(*sourceFile)
+		delete(l.files, evicted.path)
+		l.size -= evicted.size
+	}
+	return f, nil
+}
+
+// minSuffixElements is the minimum number of path elements of the suffixes used to look files up in FS
+const minSuffixElements = 2
+
+// load reads the source file at path p
+func (l *SourceLoader) load(p string) (*sourceFile, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if l.cfg.FS == nil {
+		data, err = l.readFile(os.DirFS(filepath.Dir(p)), filepath.Base(p))
+	} else {
+		name := strings.TrimLeft(filepath.ToSlash(p), "/")
+		for {
+			if data, err = l.readFile(l.cfg.FS, name); !errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			_, name, _ = strings.Cut(name, "/")
+			if strings.Count(name, "/") < minSuffixElements-1 {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := &sourceFile{
+		path:  p,
+		lines: strings.Split(string(bytes.TrimSuffix(data, []byte("\n"))), "\n"),
+		size:  int64(len(data)),
+	}
+	for i, line := range f.lines {
+		f.lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return f, nil
+}
+
+// readFile reads the file name from fsys, unless it is larger than MaxFileSize
+func (l *SourceLoader) readFile(fsys fs.FS, name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrNotExist
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if l.cfg.MaxFileSize > 0 && info.Size() > l.cfg.MaxFileSize {
+		return nil, fmt.Errorf("%s: %w", name, ErrSourceTooLarge)
+	}
+	return fs.ReadFile(fsys, name)
+}
@@ -0,0 +1,170 @@
+package stack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `package main
+
+func main() {
+	var p *int
+	println(*p)
+}
+`
+
+func TestSourceLoader(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(source), 0o644))
+
+	fsys := fstest.MapFS{
+		"app/main.go":  {Data: []byte(strings.ReplaceAll(source, "\n", "\r\n"))},
+		"app/large.go": {Data: []byte(strings.Repeat("// large\n", 100))},
+		"server.go":    {Data: []byte(source)},
+	}
+
+	cases := []struct {
+		cfg      SourceConfig
+		element  Element
+		err      bool
+		expected *Snippet
+	}{
+		{
+			cfg:     DefaultSourceConfig,
+			element: Element{File: filepath.Join(dir, "main.go"), Line: 5},
+			expected: &Snippet{
+				Line: 5,
+				Lines: []SourceLine{
+					{Number: 2, Text: ""},
+					{Number: 3, Text: "func main() {"},
+					{Number: 4, Text: "\tvar p *int"},
+					{Number: 5, Text: "\tprintln(*p)"},
+					{Number: 6, Text: "}"},
+				},
+			},
+		},
+		{
+			cfg:     SourceConfig{FS: fsys, Context: 1},
+			element: Element{File: "/build/src/app/main.go", Line: 1},
+			expected: &Snippet{
+				Line: 1,
+				Lines: []SourceLine{
+					{Number: 1, Text: "package main"},
+					{Number: 2, Text: ""},
+				},
+			},
+		},
+		{
+			cfg:     SourceConfig{FS: fsys, Context: 1},
+			element: Element{File: "/build/src/app/missing.go", Line: 1},
+			err:     true,
+		},
+		{
+			// The base name alone does not match
+			cfg:     SourceConfig{FS: fsys, Context: 1},
+			element: Element{File: "/usr/local/go/src/net/http/server.go", Line: 1},
+			err:     true,
+		},
+		{
+			cfg:     SourceConfig{FS: fsys, Context: 1},
+			element: Element{File: "/build/src/app/main.go", Line: 7},
+			err:     true,
+		},
+		{
+			cfg:     SourceConfig{FS: fsys, MaxFileSize: 100},
+			element: Element{File: "/build/src/app/large.go", Line: 1},
+			err:     true,
+		},
+		{
+			cfg:     DefaultSourceConfig,
+			element: Element{Func: "main.main()"},
+			err:     true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			snippet, err := NewCustomSourceLoader(c.cfg).Snippet(c.element)
+			if c.err {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(c.expected, snippet)
+			}
+		})
+	}
+}
+
+func TestSourceLoaderCache(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys := fstest.MapFS{
+		"a.go": {Data: []byte(strings.Repeat("a\n", 10))},
+		"b.go": {Data: []byte(strings.Repeat("b\n", 10))},
+		"c.go": {Data: []byte(strings.Repeat("c\n", 10))},
+	}
+	l := NewCustomSourceLoader(SourceConfig{FS: fsys, MaxCacheSize: 40})
+
+	for _, name := range []string{"/a.go", "/b.go", "/a.go", "/c.go"} {
+		_, err := l.Snippet(Element{File: name, Line: 1})
+		assert.NoError(err)
+	}
+	// b.go is the least recently used file
+	assert.Len(l.files, 2)
+	assert.Contains(l.files, "/a.go")
+	assert.Contains(l.files, "/c.go")
+	assert.Equal(int64(40), l.size)
+
+	// Cached files are not read again
+	delete(fsys, "a.go")
+	_, err := l.Snippet(Element{File: "/a.go", Line: 1})
+	assert.NoError(err)
+
+	// Errors are not cached
+	_, err = l.Snippet(Element{File: "/d.go", Line: 1})
+	assert.Error(err)
+	assert.NotContains(l.files, "/d.go")
+	fsys["d.go"] = &fstest.MapFile{Data: []byte("d\n")}
+	_, err = l.Snippet(Element{File: "/d.go", Line: 1})
+	assert.NoError(err)
+}
+
+func TestSourceLoaderNoCache(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys := fstest.MapFS{
+		"a.go":     {Data: []byte("a\n")},
+		"empty.go": {Data: []byte{}},
+	}
+	l := NewCustomSourceLoader(SourceConfig{FS: fsys})
+
+	for _, name := range []string{"/a.go", "/empty.go"} {
+		_, err := l.Snippet(Element{File: name, Line: 1})
+		assert.NoError(err)
+	}
+	assert.Empty(l.files)
+	assert.Zero(l.lru.Len())
+}
+
+func TestWithSnippets(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	s := Capture(0).WithSnippets(NewSourceLoader())
+	require.NotEmpty(s.Goroutines[0].Elements)
+
+	snippet := s.Goroutines[0].Elements[0].Snippet
+	require.NotNil(snippet)
+	assert.Len(snippet.Lines, 7)
+	assert.Equal(snippet.Line, snippet.Lines[3].Number)
+	assert.Contains(snippet.Lines[3].Text, "Capture(0).WithSnippets(NewSourceLoader())")
+}
@@ -4,19 +4,61 @@ import (
 	"bufio"
 	"bytes"
 	"regexp"
+	"strconv"
+	"time"
 )
 
+// Element is a frame of a goroutine's stack.
+//
+// Func and Source hold the raw lines of the frame, the other fields are parsed from them when possible.
 type Element struct {
 	Func   string `json:"func,omitempty"`
 	Source string `json:"source,omitempty"`
+
+	// Package is the import path of the function's package (e.g. "github.com/morelj/httptools/stack")
+	Package string `json:"package,omitempty"`
+	// Receiver is the receiver type of a method, when it is a pointer (e.g. "*T")
+	Receiver string `json:"receiver,omitempty"`
+	// Name is the name of the function within its package, without the receiver (e.g. "Parse" or "F.func1")
+	Name string `json:"name,omitempty"`
+	// Args are the argument words of the call, as printed by the runtime (e.g. "0xc000010000", "{0x1, 0x2}")
+	Args []string `json:"args,omitempty"`
+	// File is the path of the source file
+	File string `json:"file,omitempty"`
+	// Line is the line number in the source file
+	Line int `json:"line,omitempty"`
+	// Offset is the offset of the program counter from the start of the function
+	Offset uint64 `json:"offset,omitempty"`
+	// Snippet is the source code around the line of the frame. It is only set by Stack.WithSnippets.
+	Snippet *Snippet `json:"snippet,omitempty"`
 }
 
+// Goroutine is the stack of a goroutine.
 type Goroutine struct {
-	Name     string    `json:"name,omitempty"`
+	// Name is the goroutine part of the header line (e.g. "goroutine 6")
+	Name string `json:"name,omitempty"`
+	// State is the state of the goroutine (e.g. "running" or "chan receive")
 	State    string    `json:"state,omitempty"`
 	Elements []Element `json:"elements,omitempty"`
+
+	// ID is the goroutine ID
+	ID int `json:"id,omitempty"`
+	// Wait is the time the goroutine has been blocked, with a precision of one minute
+	Wait time.Duration `json:"wait,omitempty"`
+	// LockedToThread is true if the goroutine is locked to its OS thread
+	LockedToThread bool `json:"lockedToThread,omitempty"`
+	// Elided is true if the runtime elided some frames of the stack
+	Elided bool `json:"elided,omitempty"`
+	// CreatedBy is the frame of the go statement which created the goroutine, if any
+	CreatedBy *Element `json:"createdBy,omitempty"`
+	// CreatorID is the ID of the goroutine which created the goroutine, if printed by the runtime (Go 1.21+)
+	CreatorID int `json:"creatorId,omitempty"`
+	// Ancestors are the stacks of the goroutines which created the goroutine, as printed when
+	// GODEBUG=tracebackancestors=N is set. The state of ancestors is unknown and they have no ancestors themselves.
+	Ancestors []Goroutine `json:"ancestors,omitempty"`
 }
 
+// Stack is a parsed stack trace.
 type Stack struct {
 	Goroutines []Goroutine `json:"goroutines,omitempty"`
 	Raw        []byte      `json:"-"`
@@ -24,6 +66,9 @@ type Stack struct {
 
 var (
 	goroutineRegexp = regexp.MustCompile(`^(.+?)\s\[(.+?)\]:$`)
+	ancestorRegexp  = regexp.MustCompile(`^\[originating from goroutine (\d+)\]:$`)
+	createdByRegexp = regexp.MustCompile(`^created by \S+(?: in goroutine (\d+))?$`)
+	elidedRegexp    = regexp.MustCompile(`^\.\.\.(?:additional|\d+) frames elided\.\.\.$`)
 	funcRegexp      = regexp.MustCompile(`^(\S.+)$`)
 	sourceRegexp    = regexp.MustCompile(`^\s+(.+)$`)
 )
@@ -31,17 +76,22 @@ var (
 const (
 	goroutineNameGroup  = 1
 	goroutineStateGroup = 2
+	ancestorIDGroup     = 1
+	creatorIDGroup      = 1
 	funcGroup           = 1
 	sourceGroup         = 1
 )
 
+// Parse parses a stack trace, as returned by debug.Stack or runtime.Stack.
 func Parse(stack []byte) (Stack, error) {
 	res := Stack{
 		Raw: stack,
 	}
 
-	currentGoroutine := -1 // Index of the current goroutine
-	currentElement := -1   // Index of the current element
+	var (
+		current *Goroutine // Current goroutine or ancestor, nil until the first goroutine header
+		element *Element   // Current element, whose source line is expected
+	)
 
 	s := bufio.NewScanner(bytes.NewReader(stack))
 
@@ -49,26 +99,54 @@ func Parse(stack []byte) (Stack, error) {
 		line := s.Text()
 		if groups := goroutineRegexp.FindStringSubmatch(line); groups != nil {
 			// New goroutine
-			res.Goroutines = append(res.Goroutines, Goroutine{
-				Name:  groups[goroutineNameGroup],
-				State: groups[goroutineStateGroup],
-			})
-			currentGoroutine = len(res.Goroutines) - 1
-			currentElement = -1
-		} else if currentGoroutine >= 0 {
-			// We have at least 1 goroutine
-
-			if groups := funcRegexp.FindStringSubmatch(line); groups != nil {
-				res.Goroutines[currentGoroutine].Elements = append(res.Goroutines[currentGoroutine].Elements, Element{
-					Func: groups[funcGroup],
-				})
-				currentElement = len(res.Goroutines[currentGoroutine].Elements) - 1
-			} else if currentElement >= 0 {
-				// We have at least 1 element
+			g := Goroutine{
+				Name: groups[goroutineNameGroup],
+			}
+			g.parseHeader(groups[goroutineStateGroup])
+			res.Goroutines = append(res.Goroutines, g)
+			current = &res.Goroutines[len(res.Goroutines)-1]
+			element = nil
+			continue
+		}
+		if current == nil {
+			// We need at least 1 goroutine
+			continue
+		}
 
-				if groups := sourceRegexp.FindStringSubmatch(line); groups != nil {
-					res.Goroutines[currentGoroutine].Elements[currentElement].Source = groups[sourceGroup]
-				}
+		if groups := ancestorRegexp.FindStringSubmatch(line); groups != nil {
+			// Ancestor of the last goroutine
+			g := &res.Goroutines[len(res.Goroutines)-1]
+			id, _ := strconv.Atoi(groups[ancestorIDGroup])
+			g.Ancestors = append(g.Ancestors, Goroutine{
+				Name: "goroutine " + groups[ancestorIDGroup],
+				ID:   id,
+			})
+			current = &g.Ancestors[len(g.Ancestors)-1]
+			element = nil
+		} else if elidedRegexp.MatchString(line) {
+			current.Elided = true
+			element = nil
+		} else if groups := createdByRegexp.FindStringSubmatch(line); groups != nil {
+			e := Element{
+				Func: line,
+			}
+			e.parseFunc()
+			current.CreatedBy = &e
+			if groups[creatorIDGroup] != "" {
+				current.CreatorID, _ = strconv.Atoi(groups[creatorIDGroup])
+			}
+			element = current.CreatedBy
+		} else if groups := funcRegexp.FindStringSubmatch(line); groups != nil {
+			e := Element{
+				Func: groups[funcGroup],
+			}
+			e.parseFunc()
+			current.Elements = append(current.Elements, e)
+			element = &current.Elements[len(current.Elements)-1]
+		} else if element != nil {
+			if groups := sourceRegexp.FindStringSubmatch(line); groups != nil {
+				element.Source = groups[sourceGroup]
+				element.parseSource()
 			}
 		}
 	}
@@ -2,7 +2,9 @@ package stack
 
 import (
 	"fmt"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,22 +31,106 @@ created by testing.(*T).Run
 					{
 						Name:  "goroutine 6",
 						State: "running",
+						ID:    6,
 						Elements: []Element{
 							{
-								Func:   "runtime/debug.Stack()",
-								Source: "/opt/go/src/runtime/debug/stack.go:24 +0x88",
+								Func:    "runtime/debug.Stack()",
+								Source:  "/opt/go/src/runtime/debug/stack.go:24 +0x88",
+								Package: "runtime/debug",
+								Name:    "Stack",
+								File:    "/opt/go/src/runtime/debug/stack.go",
+								Line:    24,
+								Offset:  0x88,
 							},
 							{
-								Func:   "github.com/morelj/httptools/stack.TestParse(0x4000106ea0)",
-								Source: "/home/ubuntu/workspaces/github.com/morelj/httptools/stack/stack_test.go:13 +0x38",
+								Func:    "github.com/morelj/httptools/stack.TestParse(0x4000106ea0)",
+								Source:  "/home/ubuntu/workspaces/github.com/morelj/httptools/stack/stack_test.go:13 +0x38",
+								Package: "github.com/morelj/httptools/stack",
+								Name:    "TestParse",
+								Args:    []string{"0x4000106ea0"},
+								File:    "/home/ubuntu/workspaces/github.com/morelj/httptools/stack/stack_test.go",
+								Line:    13,
+								Offset:  0x38,
 							},
 							{
-								Func:   "testing.tRunner(0x4000106ea0, 0x1c5f50)",
-								Source: "/opt/go/src/testing/testing.go:1259 +0xf8",
+								Func:    "testing.tRunner(0x4000106ea0, 0x1c5f50)",
+								Source:  "/opt/go/src/testing/testing.go:1259 +0xf8",
+								Package: "testing",
+								Name:    "tRunner",
+								Args:    []string{"0x4000106ea0", "0x1c5f50"},
+								File:    "/opt/go/src/testing/testing.go",
+								Line:    1259,
+								Offset:  0xf8,
+							},
+						},
+						CreatedBy: &Element{
+							Func:     "created by testing.(*T).Run",
+							Source:   "/opt/go/src/testing/testing.go:1306 +0x350",
+							Package:  "testing",
+							Receiver: "*T",
+							Name:     "Run",
+							File:     "/opt/go/src/testing/testing.go",
+							Line:     1306,
+							Offset:   0x350,
+						},
+					},
+				},
+			},
+		},
+		{
+			stack: []byte(`goroutine 1 [running]:
+main.(*T).M(0x989680?, {0x568558?, 0x14a5c05fe1e0?}, ...)
+	/tmp/t.go:11 +0x13
+gopkg.in/yaml%2ev3.Marshal.func1()
+	/go/pkg/mod/gopkg.in/yaml.v3/yaml.go:42
+
+goroutine 35 [chan receive, 12 minutes, locked to thread]:
+main.worker()
+	/tmp/t.go:20 +0x29
+`),
+			expected: Stack{
+				Goroutines: []Goroutine{
+					{
+						Name:  "goroutine 1",
+						State: "running",
+						ID:    1,
+						Elements: []Element{
+							{
+								Func:     "main.(*T).M(0x989680?, {0x568558?, 0x14a5c05fe1e0?}, ...)",
+								Source:   "/tmp/t.go:11 +0x13",
+								Package:  "main",
+								Receiver: "*T",
+								Name:     "M",
+								Args:     []string{"0x989680?", "{0x568558?, 0x14a5c05fe1e0?}", "..."},
+								File:     "/tmp/t.go",
+								Line:     11,
+								Offset:   0x13,
 							},
 							{
-								Func:   "created by testing.(*T).Run",
-								Source: "/opt/go/src/testing/testing.go:1306 +0x350",
+								Func:    "gopkg.in/yaml%2ev3.Marshal.func1()",
+								Source:  "/go/pkg/mod/gopkg.in/yaml.v3/yaml.go:42",
+								Package: "gopkg.in/yaml.v3",
+								Name:    "Marshal.func1",
+								File:    "/go/pkg/mod/gopkg.in/yaml.v3/yaml.go",
+								Line:    42,
+							},
+						},
+					},
+					{
+						Name:           "goroutine 35",
+						State:          "chan receive",
+						ID:             35,
+						Wait:           12 * time.Minute,
+						LockedToThread: true,
+						Elements: []Element{
+							{
+								Func:    "main.worker()",
+								Source:  "/tmp/t.go:20 +0x29",
+								Package: "main",
+								Name:    "worker",
+								File:    "/tmp/t.go",
+								Line:    20,
+								Offset:  0x29,
 							},
 						},
 					},
@@ -63,8 +149,122 @@ created by testing.(*T).Run
 				assert.Error(err)
 			} else {
 				require.NoError(err)
+				c.expected.Raw = c.stack
 				assert.Equal(c.expected, stack)
 			}
 		})
 	}
 }
+
+func TestParseAncestors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Output of runtime.Stack(buf, true) with GODEBUG=tracebackancestors=2, using Go 1.21+
+	raw, err := os.ReadFile("testdata/ancestors.txt")
+	require.NoError(err)
+
+	stack, err := Parse(raw)
+	require.NoError(err)
+	require.Len(stack.Goroutines, 3)
+
+	main := stack.Goroutines[0]
+	assert.Equal(1, main.ID)
+	assert.Nil(main.CreatedBy)
+	assert.Zero(main.CreatorID)
+	assert.False(main.Elided)
+	assert.Empty(main.Ancestors)
+
+	// Deep recursion, some frames are elided in the middle of the stack
+	deep := stack.Goroutines[1]
+	assert.Equal(7, deep.ID)
+	assert.True(deep.Elided)
+	assert.Len(deep.Elements, 100)
+	for _, e := range deep.Elements {
+		assert.Equal("deep", e.Name)
+	}
+	require.NotNil(deep.CreatedBy)
+	assert.Equal("main", deep.CreatedBy.Name)
+	assert.Equal(25, deep.CreatedBy.Line)
+	assert.Equal(1, deep.CreatorID)
+	require.Len(deep.Ancestors, 1)
+	assert.Equal(1, deep.Ancestors[0].ID)
+
+	// Goroutine created by a goroutine which has exited
+	assert.Equal(Goroutine{
+		Name:  "goroutine 8",
+		State: "chan receive",
+		ID:    8,
+		Elements: []Element{
+			{
+				Func:    "main.worker(...)",
+				Source:  "/tmp/tb/main.go:10",
+				Package: "main",
+				Name:    "worker",
+				Args:    []string{"..."},
+				File:    "/tmp/tb/main.go",
+				Line:    10,
+			},
+		},
+		CreatedBy: &Element{
+			Func:    "created by main.spawn in goroutine 6",
+			Source:  "/tmp/tb/main.go:12 +0x59",
+			Package: "main",
+			Name:    "spawn",
+			File:    "/tmp/tb/main.go",
+			Line:    12,
+			Offset:  0x59,
+		},
+		CreatorID: 6,
+		Ancestors: []Goroutine{
+			{
+				Name: "goroutine 6",
+				ID:   6,
+				Elements: []Element{
+					{
+						Func:    "main.spawn(...)",
+						Source:  "/tmp/tb/main.go:12 +0x59",
+						Package: "main",
+						Name:    "spawn",
+						Args:    []string{"..."},
+						File:    "/tmp/tb/main.go",
+						Line:    12,
+						Offset:  0x59,
+					},
+				},
+				CreatedBy: &Element{
+					Func:    "created by main.main",
+					Source:  "/tmp/tb/main.go:24 +0x76",
+					Package: "main",
+					Name:    "main",
+					File:    "/tmp/tb/main.go",
+					Line:    24,
+					Offset:  0x76,
+				},
+			},
+			{
+				Name: "goroutine 1",
+				ID:   1,
+				Elements: []Element{
+					{
+						Func:    "main.main(...)",
+						Source:  "/tmp/tb/main.go:25 +0x76",
+						Package: "main",
+						Name:    "main",
+						Args:    []string{"..."},
+						File:    "/tmp/tb/main.go",
+						Line:    25,
+						Offset:  0x76,
+					},
+				},
+			},
+		},
+	}, stack.Goroutines[2])
+
+	// The stack is formatted back the same way
+	stack.Raw = nil
+	text, err := Parse(stack.Text())
+	require.NoError(err)
+	text.Raw = nil
+	assert.Equal(stack, text)
+}
@@ -0,0 +1,309 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+)
+
+// ErrOpenCircuit is the error wrapped by the Errors returned by a Breaker rejecting a request.
+var ErrOpenCircuit = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit.
+type BreakerState int
+
+const (
+	// Closed is the state of a healthy circuit, requests are sent.
+	Closed BreakerState = iota
+	// Open is the state of a tripped circuit, requests are rejected.
+	Open
+	// HalfOpen is the state of a circuit trying a limited number of requests, to decide whether to close again.
+	HalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// Outcome is the outcome of a request sent through a Breaker, as classified by BreakerConfig.Classify.
+type Outcome int
+
+const (
+	// Success is the outcome of a successful request.
+	Success Outcome = iota
+	// Failure is the outcome of a failed request, counting towards tripping the circuit.
+	Failure
+	// Ignored is the outcome of a request which tells nothing about the health of the server. It's not counted.
+	Ignored
+)
+
+// BreakerConfig is the configuration of a Breaker transport.
+type BreakerConfig struct {
+	// Key returns the key of the circuit used for a request. Requests with the same key share the same circuit.
+	// If nil, requests are keyed by host.
+	Key func(r *http.Request) string
+
+	// ConsecutiveFailures is the number of consecutive failures tripping the circuit. Zero disables this check.
+	ConsecutiveFailures int
+
+	// FailureRatio is the ratio of failures, over Window, tripping the circuit. Zero disables this check.
+	FailureRatio float64
+
+	// MinRequests is the minimum number of requests in Window for FailureRatio to be checked.
+	MinRequests int
+
+	// Window is the period over which requests are counted in the closed state.
+	Window time.Duration
+
+	// OpenTimeout is the time a circuit stays open before trial requests are allowed.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is the number of trial requests allowed in the half-open state.
+	// The circuit is closed once they all succeed, and opened again as soon as one fails.
+	HalfOpenRequests int
+
+	// Classify returns the outcome of a request, from its response or error.
+	// If nil, DefaultClassify is used.
+	Classify func(resp *http.Response, err error) Outcome
+
+	// OnStateChange, if set, is called when the state of a circuit changes.
+	// It's called while the circuit is locked, and must not call the Breaker.
+	OnStateChange func(key string, from, to BreakerState)
+}
+
+// DefaultBreakerConfig is the configuration used by NewBreaker.
+var DefaultBreakerConfig = BreakerConfig{
+	ConsecutiveFailures: 5,
+	FailureRatio:        0.5,
+	MinRequests:         20,
+	Window:              10 * time.Second,
+	OpenTimeout:         30 * time.Second,
+	HalfOpenRequests:    1,
+}
+
+// Breaker is an http.RoundTripper implementing the circuit breaker pattern.
+//
+// Requests rejected by an open circuit fail immediately with a 503 Error wrapping ErrOpenCircuit, with a
+// Retry-After header set to the remaining open time.
+type Breaker struct {
+	next http.RoundTripper
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewBreaker returns a Breaker transport sending requests using next.
+// If next is nil, http.DefaultTransport is used.
+// Calling NewBreaker(next) is equivalent to calling NewCustomBreaker(next, DefaultBreakerConfig)
+func NewBreaker(next http.RoundTripper) *Breaker {
+	return NewCustomBreaker(next, DefaultBreakerConfig)
+}
+
+// NewCustomBreaker returns a Breaker transport sending requests using next, configured with cfg.
+// If next is nil, http.DefaultTransport is used.
+func NewCustomBreaker(next http.RoundTripper, cfg BreakerConfig) *Breaker {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if cfg.Key == nil {
+		cfg.Key = func(r *http.Request) string {
+			return r.URL.Host
+		}
+	}
+	if cfg.Classify == nil {
+		cfg.Classify = DefaultClassify
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &Breaker{
+		next:     next,
+		cfg:      cfg,
+		now:      time.Now,
+		circuits: map[string]*circuit{},
+	}
+}
+
+// DefaultClassify is the default BreakerConfig.Classify function.
+// Network errors and 5xx responses are failures. Requests canceled or timed out by their context
+// (context.Canceled and context.DeadlineExceeded) are ignored, as they do not reflect the health of the server.
+func DefaultClassify(resp *http.Response, err error) Outcome {
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return Ignored
+	case err != nil || resp.StatusCode >= 500:
+		return Failure
+	default:
+		return Success
+	}
+}
+
+// State returns the current state of the circuit identified by key
+func (b *Breaker) State(key string) BreakerState {
+	c := b.circuit(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh(b, b.now())
+	return c.state
+}
+
+// RoundTrip implements http.RoundTripper
+func (b *Breaker) RoundTrip(r *http.Request) (*http.Response, error) {
+	key := b.cfg.Key(r)
+	c := b.circuit(key)
+
+	generation, retryAfter, ok := c.allow(b, b.now())
+	if !ok {
+		err := httperror.NewWithErrorf(ErrOpenCircuit, http.StatusServiceUnavailable, "Service unavailable: %s", key)
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		return nil, httperror.WithHeader(err, header.RetryAfter, strconv.Itoa(max(seconds, 1)))
+	}
+
+	resp, err := b.next.RoundTrip(r)
+	c.record(b, generation, b.cfg.Classify(resp, err), b.now())
+	return resp, err
+}
+
+// circuit returns the circuit for key, creating it if needed
+func (b *Breaker) circuit(key string) *circuit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{key: key}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+// circuit is the state of a single circuit
+type circuit struct {
+	key string
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64 // Incremented on every state change, to ignore results of requests from a previous state
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+	consecutive int
+	trials      int
+	successes   int
+}
+
+// allow returns true if a request can be sent, along with the generation of the circuit.
+// If the request is rejected, the remaining time before the circuit becomes half-open is returned.
+func (c *circuit) allow(b *Breaker, now time.Time) (uint64, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refresh(b, now)
+	switch c.state {
+	case Open:
+		return 0, c.openedAt.Add(b.cfg.OpenTimeout).Sub(now), false
+
+	case HalfOpen:
+		if c.trials >= b.cfg.HalfOpenRequests {
+			return 0, 0, false
+		}
+		c.trials++
+	}
+	return c.generation, 0, true
+}
+
+// record records the outcome of a request sent during the given generation
+func (c *circuit) record(b *Breaker, generation uint64, outcome Outcome, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if outcome == Ignored {
+		if c.state == HalfOpen {
+			// Give the trial back
+			c.trials--
+		}
+		return
+	}
+
+	success := outcome == Success
+	switch c.state {
+	case Closed:
+		c.requests++
+		if success {
+			c.consecutive = 0
+			return
+		}
+		c.failures++
+		c.consecutive++
+		if (b.cfg.ConsecutiveFailures > 0 && c.consecutive >= b.cfg.ConsecutiveFailures) ||
+			(b.cfg.FailureRatio > 0 && c.requests >= b.cfg.MinRequests &&
+				float64(c.failures)/float64(c.requests) >= b.cfg.FailureRatio) {
+			c.setState(b, Open, now)
+		}
+
+	case HalfOpen:
+		if !success {
+			c.setState(b, Open, now)
+			return
+		}
+		c.successes++
+		if c.successes >= b.cfg.HalfOpenRequests {
+			c.setState(b, Closed, now)
+		}
+	}
+}
+
+// refresh updates the state of the circuit according to the time
+func (c *circuit) refresh(b *Breaker, now time.Time) {
+	switch c.state {
+	case Closed:
+		if b.cfg.Window > 0 && now.Sub(c.windowStart) >= b.cfg.Window {
+			c.windowStart = now
+			c.requests = 0
+			c.failures = 0
+		}
+
+	case Open:
+		if now.Sub(c.openedAt) >= b.cfg.OpenTimeout {
+			c.setState(b, HalfOpen, now)
+		}
+	}
+}
+
+func (c *circuit) setState(b *Breaker, state BreakerState, now time.Time) {
+	from := c.state
+	c.state = state
+	c.generation++
+	c.requests, c.failures, c.consecutive = 0, 0, 0
+	c.trials, c.successes = 0, 0
+	c.windowStart = now
+	if state == Open {
+		c.openedAt = now
+	}
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(c.key, from, state)
+	}
+}
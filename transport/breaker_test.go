@@ -0,0 +1,176 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morelj/httptools/header"
+	"github.com/morelj/httptools/httperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	status := http.StatusInternalServerError
+	calls := 0
+	next := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	})
+
+	var transitions []string
+	cfg := DefaultBreakerConfig
+	cfg.ConsecutiveFailures = 3
+	cfg.OnStateChange = func(key string, from, to BreakerState) {
+		transitions = append(transitions, key+": "+from.String()+" -> "+to.String())
+	}
+	b := NewCustomBreaker(next, cfg)
+	b.now = func() time.Time { return now }
+
+	send := func(host string) (*http.Response, error) {
+		r, _ := http.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+		return b.RoundTrip(r)
+	}
+
+	// Trip the circuit
+	for i := 0; i < 3; i++ {
+		_, err := send("a")
+		require.NoError(err)
+	}
+	assert.Equal(Open, b.State("a"))
+	assert.Equal(Closed, b.State("b"))
+
+	// Requests are rejected
+	_, err := send("a")
+	var httpErr httperror.Error
+	require.True(errors.As(err, &httpErr))
+	assert.Equal(http.StatusServiceUnavailable, httpErr.StatusCode())
+	assert.Equal("30", httperror.Header(err).Get(header.RetryAfter))
+	assert.ErrorIs(err, ErrOpenCircuit)
+	assert.Equal(3, calls)
+
+	// Other hosts are not affected
+	_, err = send("b")
+	assert.NoError(err)
+
+	// A failing trial opens the circuit again
+	now = now.Add(30 * time.Second)
+	assert.Equal(HalfOpen, b.State("a"))
+	_, err = send("a")
+	assert.NoError(err)
+	assert.Equal(Open, b.State("a"))
+
+	// A successful trial closes it
+	now = now.Add(30 * time.Second)
+	status = http.StatusOK
+	_, err = send("a")
+	assert.NoError(err)
+	assert.Equal(Closed, b.State("a"))
+
+	assert.Equal([]string{
+		"a: closed -> open",
+		"a: open -> half-open",
+		"a: half-open -> open",
+		"a: open -> half-open",
+		"a: half-open -> closed",
+	}, transitions)
+}
+
+func TestBreakerFailureRatio(t *testing.T) {
+	assert := assert.New(t)
+
+	fail := false
+	next := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	cfg := DefaultBreakerConfig
+	cfg.ConsecutiveFailures = 0
+	cfg.FailureRatio = 0.5
+	cfg.MinRequests = 4
+	b := NewCustomBreaker(next, cfg)
+
+	for i := 0; i < 4; i++ {
+		fail = i%2 == 1
+		r, _ := http.NewRequest(http.MethodGet, "http://host/", nil)
+		b.RoundTrip(r)
+		if i < 3 {
+			assert.Equal(Closed, b.State("host"))
+		}
+	}
+	assert.Equal(Open, b.State("host"))
+}
+
+func TestDefaultClassify(t *testing.T) {
+	cases := []struct {
+		resp    *http.Response
+		err     error
+		outcome Outcome
+	}{
+		{resp: &http.Response{StatusCode: http.StatusOK}, outcome: Success},
+		{resp: &http.Response{StatusCode: http.StatusNotFound}, outcome: Success},
+		{resp: &http.Response{StatusCode: http.StatusBadGateway}, outcome: Failure},
+		{err: errors.New("connection refused"), outcome: Failure},
+		{err: &url.Error{Op: "Get", URL: "http://a/", Err: context.Canceled}, outcome: Ignored},
+		{err: &url.Error{Op: "Get", URL: "http://a/", Err: context.DeadlineExceeded}, outcome: Ignored},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert.Equal(t, c.outcome, DefaultClassify(c.resp, c.err))
+		})
+	}
+}
+
+func TestBreakerIgnored(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	var err error
+	next := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, err
+	})
+	cfg := DefaultBreakerConfig
+	cfg.ConsecutiveFailures = 2
+	b := NewCustomBreaker(next, cfg)
+	b.now = func() time.Time { return now }
+
+	send := func(e error) {
+		err = e
+		r, _ := http.NewRequest(http.MethodGet, "http://a/", nil)
+		b.RoundTrip(r)
+	}
+	failure := errors.New("connection refused")
+
+	// An ignored request does not reset the consecutive failures
+	send(failure)
+	send(context.Canceled)
+	assert.Equal(Closed, b.State("a"))
+	send(failure)
+	assert.Equal(Open, b.State("a"))
+
+	// An ignored trial does not close the circuit, and gives its trial back
+	now = now.Add(cfg.OpenTimeout)
+	assert.Equal(HalfOpen, b.State("a"))
+	send(context.Canceled)
+	assert.Equal(HalfOpen, b.State("a"))
+	send(failure)
+	assert.Equal(Open, b.State("a"))
+}
@@ -0,0 +1,209 @@
+// Package transport provides http.RoundTripper implementations for outgoing HTTP calls.
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/morelj/httptools/header"
+)
+
+// RetryConfig is the configuration of a Retry transport.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+
+	// MaxElapsedTime is the maximum time spent on a request, including all the attempts and waits.
+	// No retry is made once it's elapsed. Zero means no limit.
+	MaxElapsedTime time.Duration
+
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum wait between two attempts.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor applied to the backoff after each attempt.
+	Multiplier float64
+
+	// Jitter is the ratio of randomization applied to backoffs, between 0 and 1.
+	// A backoff b is replaced by a random duration between b*(1-Jitter) and b*(1+Jitter).
+	Jitter float64
+
+	// ShouldRetry decides whether a request must be retried, given the result of the last attempt.
+	// If nil, DefaultShouldRetry is used.
+	ShouldRetry func(r *http.Request, resp *http.Response, err error) bool
+}
+
+// DefaultRetryConfig is the configuration used by NewRetry.
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts:    4,
+	MaxElapsedTime: 30 * time.Second,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.5,
+}
+
+// Retry is an http.RoundTripper retrying failed requests with an exponential backoff.
+type Retry struct {
+	next http.RoundTripper
+	cfg  RetryConfig
+
+	// sleep waits for d or until ctx is done, can be replaced for testing
+	sleep func(ctx context.Context, d time.Duration) error
+	now   func() time.Time
+}
+
+// NewRetry returns a Retry transport sending requests using next.
+// If next is nil, http.DefaultTransport is used.
+// Calling NewRetry(next) is equivalent to calling NewCustomRetry(next, DefaultRetryConfig)
+func NewRetry(next http.RoundTripper) *Retry {
+	return NewCustomRetry(next, DefaultRetryConfig)
+}
+
+// NewCustomRetry returns a Retry transport sending requests using next, configured with cfg.
+// If next is nil, http.DefaultTransport is used.
+//
+// By default, idempotent requests are retried on network errors, 429 and 5xx responses (except 501).
+// When the response has a Retry-After header, either in seconds or as an HTTP-date, it's used instead of the
+// computed backoff, unless it exceeds MaxElapsedTime.
+// Requests with a body are retried only if their body can be rewinded using GetBody.
+func NewCustomRetry(next http.RoundTripper, cfg RetryConfig) *Retry {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if cfg.ShouldRetry == nil {
+		cfg.ShouldRetry = DefaultShouldRetry
+	}
+	return &Retry{
+		next:  next,
+		cfg:   cfg,
+		sleep: sleep,
+		now:   time.Now,
+	}
+}
+
+// DefaultShouldRetry returns true if r is idempotent and either err is a network error, or the response has a
+// 429 or 5xx status code (except 501 Not Implemented).
+func DefaultShouldRetry(r *http.Request, resp *http.Response, err error) bool {
+	if !isIdempotent(r) {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+}
+
+// isIdempotent returns true if the method of r is idempotent, or if it has an Idempotency-Key header
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != "" || r.Header.Get("X-Idempotency-Key") != ""
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Retry) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	start := t.now()
+	backoff := t.cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		req := r
+		if attempt > 1 && r.Body != nil && r.Body != http.NoBody {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = r.Clone(ctx)
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+
+		if attempt >= t.cfg.MaxAttempts || !t.canRewind(r) || !t.cfg.ShouldRetry(r, resp, err) {
+			return resp, err
+		}
+
+		wait := t.jitter(backoff)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get(header.RetryAfter), t.now()); ok {
+				wait = retryAfter
+			}
+		}
+		if t.cfg.MaxElapsedTime > 0 && t.now().Add(wait).Sub(start) > t.cfg.MaxElapsedTime {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain the body so that the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		if err := t.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+
+		backoff = time.Duration(float64(backoff) * t.cfg.Multiplier)
+		if t.cfg.MaxBackoff > 0 && backoff > t.cfg.MaxBackoff {
+			backoff = t.cfg.MaxBackoff
+		}
+	}
+}
+
+// canRewind returns true if the body of r can be sent again
+func (t *Retry) canRewind(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+// jitter randomizes d according to the configured jitter
+func (t *Retry) jitter(d time.Duration) time.Duration {
+	if t.cfg.Jitter <= 0 {
+		return d
+	}
+	delta := t.cfg.Jitter * float64(d)
+	return time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either in seconds or as an HTTP-date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		d := date.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
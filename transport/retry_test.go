@@ -0,0 +1,157 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morelj/httptools/header"
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripperFunc is a function implementing http.RoundTripper
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+type attempt struct {
+	status     int
+	retryAfter string
+	err        error
+}
+
+func TestRetry(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	networkErr := errors.New("connection reset")
+
+	cases := []struct {
+		method   string
+		attempts []attempt
+		status   int
+		err      bool
+		waits    []time.Duration
+	}{
+		{
+			method:   http.MethodGet,
+			attempts: []attempt{{status: 200}},
+			status:   200,
+		},
+		{
+			method:   http.MethodGet,
+			attempts: []attempt{{status: 503}, {err: networkErr}, {status: 200}},
+			status:   200,
+			waits:    []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			method:   http.MethodPut,
+			attempts: []attempt{{status: 429, retryAfter: "3"}, {status: 503, retryAfter: now.Add(2 * time.Second).Format(http.TimeFormat)}, {status: 204}},
+			status:   204,
+			waits:    []time.Duration{3 * time.Second, 2 * time.Second},
+		},
+		{
+			method:   http.MethodGet,
+			attempts: []attempt{{status: 500}, {status: 500}, {status: 500}, {status: 502}},
+			status:   502,
+			waits:    []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			method:   http.MethodPost,
+			attempts: []attempt{{status: 503}},
+			status:   503,
+		},
+		{
+			method:   http.MethodGet,
+			attempts: []attempt{{status: 404}},
+			status:   404,
+		},
+		{
+			method:   http.MethodGet,
+			attempts: []attempt{{status: 503, retryAfter: "60"}},
+			status:   503,
+		},
+		{
+			method:   http.MethodGet,
+			attempts: []attempt{{err: networkErr}, {err: networkErr}, {err: networkErr}, {err: networkErr}},
+			err:      true,
+			waits:    []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			var bodies []string
+			count := 0
+			next := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				a := c.attempts[count]
+				count++
+				if r.Body != nil {
+					data, _ := io.ReadAll(r.Body)
+					bodies = append(bodies, string(data))
+				}
+				if a.err != nil {
+					return nil, a.err
+				}
+				resp := &http.Response{
+					StatusCode: a.status,
+					Header:     http.Header{},
+					Body:       io.NopCloser(strings.NewReader("")),
+				}
+				if a.retryAfter != "" {
+					resp.Header.Set(header.RetryAfter, a.retryAfter)
+				}
+				return resp, nil
+			})
+
+			cfg := DefaultRetryConfig
+			cfg.Jitter = 0
+			cfg.MaxBackoff = 300 * time.Millisecond
+			cfg.MaxElapsedTime = 10 * time.Second
+			rt := NewCustomRetry(next, cfg)
+			var waits []time.Duration
+			rt.now = func() time.Time { return now }
+			rt.sleep = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+
+			r, _ := http.NewRequest(c.method, "http://localhost/", strings.NewReader("body"))
+			resp, err := rt.RoundTrip(r)
+			if c.err {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(c.status, resp.StatusCode)
+			}
+			assert.Equal(len(c.attempts), count)
+			assert.Equal(c.waits, waits)
+			for _, body := range bodies {
+				assert.Equal("body", body)
+			}
+		})
+	}
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	rt := NewRetry(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		count++
+		cancel()
+		return nil, errors.New("connection reset")
+	}))
+
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/", nil)
+	_, err := rt.RoundTrip(r)
+	assert.ErrorIs(err, context.Canceled)
+	assert.Equal(1, count)
+}